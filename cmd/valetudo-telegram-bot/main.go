@@ -6,18 +6,35 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/bot"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/storage"
 	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 )
 
+type RobotConfig struct {
+	Name string
+	Url  string
+}
+
 type BotConfig struct {
 	TelegramBotToken string
 	TelegramChatIds  []string
 	ValetudoUrl      string
+	Robots           []RobotConfig
+	ValetudoOptions  valetudo.ClientOptions
 	TelegramDebug    bool
+	DataPath         string
+	StorageType      string
+
+	ConsumablePercentThreshold int
+	ConsumableMinutesThreshold int
+
+	Timezone           *time.Location
+	ScheduleMinBattery int
 }
 
 func parseTelegramChatIds(chatIds string) []string {
@@ -28,12 +45,120 @@ func parseTelegramChatIds(chatIds string) []string {
 	return strings.Split(chatIds, ",")
 }
 
+// parseRobots parses "name=url,name=url" list of robots
+func parseRobots(robots string) []RobotConfig {
+	result := []RobotConfig{}
+
+	if robots == "" {
+		return result
+	}
+
+	for _, item := range strings.Split(robots, ",") {
+		name, url, found := strings.Cut(strings.TrimSpace(item), "=")
+		name = strings.TrimSpace(name)
+
+		if !found || name == "" || strings.ContainsAny(name, " @") {
+			log.Panic(fmt.Errorf("failed to parse ROBOTS, expected name=url, got %s", item))
+		}
+
+		// robots are picked by name regardless of case, the other one couldn't be reached
+		for _, robot := range result {
+			if strings.EqualFold(robot.Name, name) {
+				log.Panic(fmt.Errorf("failed to parse ROBOTS, name %s is used more than once", name))
+			}
+		}
+
+		result = append(result, RobotConfig{Name: name, Url: strings.TrimSpace(url)})
+	}
+
+	if len(result) > bot.MaxRobots {
+		log.Panic(fmt.Errorf("failed to parse ROBOTS, at most %d robots are supported", bot.MaxRobots))
+	}
+
+	return result
+}
+
+// parseHeaders parses "Name: value; Name: value" list of headers
+func parseHeaders(headers string) map[string]string {
+	result := map[string]string{}
+
+	for _, item := range strings.Split(headers, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(item, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			log.Panic(fmt.Errorf("failed to parse VALETUDO_HEADERS, expected Name: value, got %s", item))
+		}
+
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return result
+}
+
+func getEnvOrDefault(key string, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func getEnvIntOrDefault(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Panic(fmt.Errorf("failed to parse %s: %w", key, err))
+	}
+
+	return parsed
+}
+
+func getEnvLocationOrDefault(key string, fallback *time.Location) *time.Location {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		log.Panic(fmt.Errorf("failed to parse %s: %w", key, err))
+	}
+
+	return location
+}
+
 func loadConfig() *BotConfig {
 	return &BotConfig{
 		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
 		TelegramChatIds:  parseTelegramChatIds(os.Getenv("TELEGRAM_CHAT_IDS")),
 		TelegramDebug:    os.Getenv("TELEGRAM_DEBUG") == "true",
 		ValetudoUrl:      os.Getenv("VALETUDO_URL"),
+		Robots:           parseRobots(os.Getenv("ROBOTS")),
+		ValetudoOptions: valetudo.ClientOptions{
+			Username:           os.Getenv("VALETUDO_USERNAME"),
+			Password:           os.Getenv("VALETUDO_PASSWORD"),
+			CAFile:             os.Getenv("VALETUDO_CA_FILE"),
+			CertFile:           os.Getenv("VALETUDO_CERT_FILE"),
+			KeyFile:            os.Getenv("VALETUDO_KEY_FILE"),
+			InsecureSkipVerify: os.Getenv("VALETUDO_INSECURE_SKIP_VERIFY") == "true",
+			Headers:            parseHeaders(os.Getenv("VALETUDO_HEADERS")),
+		},
+		DataPath:    getEnvOrDefault("DATA_PATH", "data.json"),
+		StorageType: getEnvOrDefault("STORAGE_TYPE", "file"),
+
+		ConsumablePercentThreshold: getEnvIntOrDefault("CONSUMABLE_THRESHOLD_PERCENT", 10),
+		ConsumableMinutesThreshold: getEnvIntOrDefault("CONSUMABLE_THRESHOLD_MINUTES", 600),
+
+		Timezone:           getEnvLocationOrDefault("TIMEZONE", time.Local),
+		ScheduleMinBattery: getEnvIntOrDefault("SCHEDULE_MIN_BATTERY", 20),
 	}
 }
 
@@ -45,13 +170,16 @@ func main() {
 		log.Panic("TELEGRAM_BOT_TOKEN is not set")
 	}
 
-	if config.ValetudoUrl == "" {
-		log.Panic("VALETUDO_URL is not set")
+	if len(config.Robots) == 0 {
+		if config.ValetudoUrl == "" {
+			log.Panic("VALETUDO_URL or ROBOTS is not set")
+		}
+
+		config.Robots = []RobotConfig{{Url: config.ValetudoUrl}}
 	}
 
 	log.Println("Starting Valetudo Telegram Bot")
 
-	api := valetudo.Init(config.ValetudoUrl)
 	telegramBot, err := tgbotapi.NewBotAPI(config.TelegramBotToken)
 	if err != nil {
 		log.Panic(fmt.Errorf("failed to initialize telegram integration, have you set your bot token? %w", err))
@@ -59,7 +187,32 @@ func main() {
 
 	telegramBot.Debug = config.TelegramDebug
 
-	botApp := bot.NewBot(&api, telegramBot)
+	botStorage, err := storage.Open(config.StorageType, config.DataPath)
+	if err != nil {
+		log.Panic(fmt.Errorf("failed to open data storage: %w", err))
+	}
+
+	robotApis := []*valetudo.ValetudoClient{}
+	for _, robot := range config.Robots {
+		api, err := valetudo.InitWithOptions(robot.Url, config.ValetudoOptions)
+		if err != nil {
+			log.Panic(fmt.Errorf("failed to initialize valetudo client: %w", err))
+		}
+
+		robotApis = append(robotApis, &api)
+	}
+
+	// the first robot keeps data stored before more robots were added
+	botApp := bot.NewBot(robotApis[0], telegramBot, botStorage)
+	botApp.SetName(config.Robots[0].Name)
+
+	for i, robot := range config.Robots[1:] {
+		botApp.AddRobot(robot.Name, robotApis[i+1])
+	}
+
+	botApp.SetConsumableThresholds(config.ConsumablePercentThreshold, config.ConsumableMinutesThreshold)
+	botApp.SetTimezone(config.Timezone)
+	botApp.SetScheduleMinBattery(config.ScheduleMinBattery)
 
 	for _, id := range config.TelegramChatIds {
 		chatId, err := strconv.ParseInt(id, 10, 64)
@@ -3,11 +3,14 @@ module github.com/SkaceKamen/valetudo-telegram-bot
 go 1.21.6
 
 require (
-	github.com/fogleman/gg v1.3.0 // indirect
-	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1 // indirect
+	github.com/fogleman/gg v1.3.0
+	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/joho/godotenv v1.5.1
+	github.com/r3labs/sse/v2 v2.10.0
+)
+
+require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/r3labs/sse/v2 v2.10.0 // indirect
 	golang.org/x/image v0.15.0 // indirect
 	golang.org/x/net v0.0.0-20191116160921-f9c825593386 // indirect
 	gopkg.in/cenkalti/backoff.v1 v1.1.0 // indirect
@@ -0,0 +1,307 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// max passes offered in the room keyboard
+const maxKeyboardIterations = 3
+
+// rooms are laid out in two columns when there's more of them than this
+const singleColumnRoomsLimit = 8
+
+// selections of room pickers nobody touched for this long are forgotten, pressing their buttons starts over
+const cleanSelectionMaxAge = 24 * time.Hour
+
+// cleanSelection is the state of a room picker message. It's kept on our side
+// because callback data is limited to 64 bytes, which isn't enough to carry
+// the selected segments for bigger homes.
+type cleanSelection struct {
+	/** selected segments in the order they were picked */
+	SegmentIds []string
+	Iterations int
+	/** last time the picker was used, see cleanSelectionMaxAge */
+	UpdatedAt time.Time
+}
+
+func (selection *cleanSelection) indexOf(segmentId string) int {
+	for i, id := range selection.SegmentIds {
+		if id == segmentId {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (selection *cleanSelection) toggle(segmentId string) {
+	index := selection.indexOf(segmentId)
+
+	if index == -1 {
+		selection.SegmentIds = append(selection.SegmentIds, segmentId)
+		return
+	}
+
+	selection.SegmentIds = append(selection.SegmentIds[:index], selection.SegmentIds[index+1:]...)
+}
+
+func cleanSelectionKey(chatId int64, messageId int) string {
+	return fmt.Sprintf("%d:%d", chatId, messageId)
+}
+
+func (bot *Bot) getCleanSelection(chatId int64, messageId int) *cleanSelection {
+	selection, ok := bot.cleanSelections[cleanSelectionKey(chatId, messageId)]
+	if !ok {
+		// selection could've been lost on restart or expired, start over
+		selection = &cleanSelection{Iterations: 1}
+		bot.storeCleanSelection(chatId, messageId, selection)
+	}
+
+	selection.UpdatedAt = time.Now()
+
+	return selection
+}
+
+// storeCleanSelection keeps selection of a new picker message and forgets pickers that weren't used for a long time
+func (bot *Bot) storeCleanSelection(chatId int64, messageId int, selection *cleanSelection) {
+	for key, stored := range bot.cleanSelections {
+		if time.Since(stored.UpdatedAt) > cleanSelectionMaxAge {
+			delete(bot.cleanSelections, key)
+		}
+	}
+
+	selection.UpdatedAt = time.Now()
+	bot.cleanSelections[cleanSelectionKey(chatId, messageId)] = selection
+}
+
+func buildCleanKeyboard(rooms []valetudo.RobotStateMapLayer, selection *cleanSelection, maxIterations int) tgbotapi.InlineKeyboardMarkup {
+	keyboardButtons := [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("💯 Everything", "clean all")},
+	}
+
+	maxIterations = min(maxIterations, maxKeyboardIterations)
+	if maxIterations > 1 {
+		passesRow := []tgbotapi.InlineKeyboardButton{}
+
+		for i := 1; i <= maxIterations; i++ {
+			label := fmt.Sprintf("%dx", i)
+			if i == selection.Iterations {
+				label = "✅ " + label
+			}
+
+			passesRow = append(passesRow, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("clean passes %d", i)))
+		}
+
+		keyboardButtons = append(keyboardButtons, passesRow)
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return strings.Compare(*rooms[i].Metadata.Name, *rooms[j].Metadata.Name) < 0
+	})
+
+	columns := 1
+	if len(rooms) > singleColumnRoomsLimit {
+		columns = 2
+	}
+
+	row := []tgbotapi.InlineKeyboardButton{}
+	for _, layer := range rooms {
+		label := "⬜ " + *layer.Metadata.Name
+		if index := selection.indexOf(*layer.Metadata.SegmentId); index != -1 {
+			label = fmt.Sprintf("✅ %d. %s", index+1, *layer.Metadata.Name)
+		}
+
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "clean toggle "+*layer.Metadata.SegmentId))
+
+		if len(row) == columns {
+			keyboardButtons = append(keyboardButtons, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+	}
+
+	if len(row) > 0 {
+		keyboardButtons = append(keyboardButtons, row)
+	}
+
+	if len(selection.SegmentIds) > 0 {
+		label := fmt.Sprintf("🧹 Start (%d rooms)", len(selection.SegmentIds))
+		if len(selection.SegmentIds) == 1 {
+			label = "🧹 Start (1 room)"
+		}
+
+		keyboardButtons = append(keyboardButtons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label+formatIterations(selection.Iterations), "clean start"),
+		))
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: keyboardButtons,
+	}
+}
+
+// buildRobotCleanKeyboard offers rooms only when the robot can clean them, otherwise just the whole home
+func (bot *Bot) buildRobotCleanKeyboard(selection *cleanSelection) (tgbotapi.InlineKeyboardMarkup, error) {
+	if !bot.HasCapability("MapSegmentationCapability") {
+		return buildCleanKeyboard(nil, selection, 1), nil
+	}
+
+	rooms, err := bot.getRooms()
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	properties, err := bot.robotApi.GetMapSegmentationCapabilityProperties()
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	return buildCleanKeyboard(*rooms, selection, properties.IterationCount.Max), nil
+}
+
+func (bot *Bot) refreshCleanKeyboard(query *tgbotapi.CallbackQuery, selection *cleanSelection) error {
+	keyboard, err := bot.buildRobotCleanKeyboard(selection)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(
+		query.Message.Chat.ID,
+		query.Message.MessageID,
+		keyboard,
+	))
+
+	return err
+}
+
+func (bot *Bot) getRoomNames(segmentIds []string) []string {
+	names := []string{}
+	rooms, err := bot.getRooms()
+
+	if err != nil {
+		log.Println(err)
+		return segmentIds
+	}
+
+	for _, segmentId := range segmentIds {
+		name := segmentId
+		for _, room := range *rooms {
+			if *room.Metadata.SegmentId == segmentId {
+				name = *room.Metadata.Name
+				break
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (bot *Bot) handleCleanCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) < 1 {
+		err := bot.handleCleanCommand(query.Message.Chat.ID, "")
+		callback := tgbotapi.NewCallback(query.ID, "You need to pick what")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			log.Println(err)
+		}
+
+		return err
+	}
+
+	selectionKey := cleanSelectionKey(query.Message.Chat.ID, query.Message.MessageID)
+	selection := bot.getCleanSelection(query.Message.Chat.ID, query.Message.MessageID)
+
+	switch args[0] {
+	case "all":
+		delete(bot.cleanSelections, selectionKey)
+
+		// errors are reported to the user by handleOneTimeCallback
+		bot.handleOneTimeCallback(query, args, func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			err := bot.cleanEverything()
+			if err != nil {
+				return "", err
+			}
+
+			return "🧹 Cleaning everything", nil
+		})
+
+		return nil
+
+	case "passes":
+		if len(args) < 2 {
+			return nil
+		}
+
+		iterations, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		selection.Iterations = iterations
+
+		return bot.refreshCleanKeyboard(query, selection)
+
+	case "toggle":
+		if len(args) < 2 {
+			return nil
+		}
+
+		selection.toggle(args[1])
+
+		return bot.refreshCleanKeyboard(query, selection)
+
+	case "start":
+		if len(selection.SegmentIds) == 0 {
+			callback := tgbotapi.NewCallback(query.ID, "You need to pick at least one room")
+			_, err := bot.telegramApi.Request(callback)
+
+			return err
+		}
+
+		bot.handleOneTimeCallback(query, args, func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			err := bot.cleanSegments(selection.SegmentIds, selection.Iterations)
+			if err != nil {
+				return "", err
+			}
+
+			// the picker is replaced by the result
+			delete(bot.cleanSelections, selectionKey)
+
+			return "🧹 Cleaning " + strings.Join(bot.getRoomNames(selection.SegmentIds), ", ") + formatIterations(selection.Iterations), nil
+		})
+
+		return nil
+	}
+
+	// single room buttons sent by older versions of the bot
+	segmentId := args[0]
+	iterations := 1
+
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err == nil {
+			iterations = parsed
+		}
+	}
+
+	delete(bot.cleanSelections, selectionKey)
+
+	bot.handleOneTimeCallback(query, args, func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+		err := bot.cleanSegments([]string{segmentId}, iterations)
+		if err != nil {
+			return "", err
+		}
+
+		return "🧹 Cleaning " + strings.Join(bot.getRoomNames([]string{segmentId}), ", ") + formatIterations(iterations), nil
+	})
+
+	return nil
+}
@@ -2,14 +2,15 @@ package bot
 
 import (
 	"fmt"
-	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo_map_renderer"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func (bot *Bot) publishMyCommands() error {
+// getAvailableCommands lists commands supported by any of the robots, regardless of user role
+func (bot *Bot) getAvailableCommands() []tgbotapi.BotCommand {
 	baseCommands := []tgbotapi.BotCommand{
 		{
 			Command:     "clean",
@@ -31,9 +32,103 @@ func (bot *Bot) publishMyCommands() error {
 			Command:     "status",
 			Description: "Get current status",
 		},
+		{
+			Command:     "notify",
+			Description: "Choose notifications and quiet hours",
+		},
+		{
+			Command:     "history",
+			Description: "Show past cleaning sessions",
+		},
+		{
+			Command:     "timers",
+			Description: "Manage robot timers",
+		},
+		{
+			Command:     "schedule",
+			Description: "Manage cleaning schedule run by the bot",
+		},
+		{
+			Command:     "users",
+			Description: "Manage who can use the bot",
+		},
+		{
+			Command:     "topic",
+			Description: "Send notifications to the current forum topic",
+		},
+	}
+
+	if bot.anyRobotHasCapability("MapSegmentRenameCapability") || bot.anyRobotHasCapability("MapSegmentEditCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "rooms",
+				Description: "Rename, join or split rooms",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("CombinedVirtualRestrictionsCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "restrictions",
+				Description: "Toggle virtual walls and no-go areas",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("ZoneCleaningCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "zone",
+				Description: "Clean or manage saved zones",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("LocateCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "locate",
+				Description: "Play sound and show where the robot is",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("ManualControlCapability") || bot.anyRobotHasCapability("HighResolutionManualControlCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "drive",
+				Description: "Drive the robot manually",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("GoToLocationCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "goto",
+				Description: "Send robot to a saved point",
+			},
+		)
+	}
+
+	if bot.anyRobotHasCapability("ConsumableMonitoringCapability") {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "consumables",
+				Description: "Show and reset consumables",
+			},
+		)
 	}
 
-	if bot.HasCapability("OperationModeControlCapability") {
+	if bot.anyRobotHasCapability("OperationModeControlCapability") {
 		baseCommands = append(
 			baseCommands,
 			tgbotapi.BotCommand{
@@ -43,7 +138,7 @@ func (bot *Bot) publishMyCommands() error {
 		)
 	}
 
-	if bot.HasCapability("FanSpeedControlCapability") {
+	if bot.anyRobotHasCapability("FanSpeedControlCapability") {
 		baseCommands = append(
 			baseCommands,
 			tgbotapi.BotCommand{
@@ -53,7 +148,7 @@ func (bot *Bot) publishMyCommands() error {
 		)
 	}
 
-	if bot.HasCapability("WaterUsageControlCapability") {
+	if bot.anyRobotHasCapability("WaterUsageControlCapability") {
 		baseCommands = append(
 			baseCommands,
 			tgbotapi.BotCommand{
@@ -63,9 +158,37 @@ func (bot *Bot) publishMyCommands() error {
 		)
 	}
 
+	if len(bot.robots) > 1 {
+		baseCommands = append(
+			baseCommands,
+			tgbotapi.BotCommand{
+				Command:     "robot",
+				Description: "Choose which robot commands control",
+			},
+		)
+	}
+
+	return baseCommands
+}
+
+// getCommandsForRole filters available commands to the ones user with specified role can use
+func (bot *Bot) getCommandsForRole(role userRole) []tgbotapi.BotCommand {
+	result := []tgbotapi.BotCommand{}
+
+	for _, command := range bot.getAvailableCommands() {
+		if role.allows(requiredRole(commandRoles, []string{command.Command})) {
+			result = append(result, command)
+		}
+	}
+
+	return result
+}
+
+func (bot *Bot) publishMyCommands() error {
+	// strangers only see what viewers can do, everyone else gets commands for their role
 	_, err := bot.telegramApi.Request(
 		tgbotapi.NewSetMyCommands(
-			baseCommands...,
+			bot.getCommandsForRole(roleViewer)...,
 		),
 	)
 
@@ -73,7 +196,125 @@ func (bot *Bot) publishMyCommands() error {
 		return fmt.Errorf("failed to set my commands: %w", err)
 	}
 
-	return err
+	for _, user := range bot.getUserIds() {
+		err = bot.publishUserCommands(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishUserCommands updates command list shown to the user, users without access get the default one
+func (bot *Bot) publishUserCommands(userId int64) error {
+	scope := tgbotapi.NewBotCommandScopeChat(userId)
+	role := bot.getUserRole(userId)
+
+	if role == "" {
+		_, err := bot.telegramApi.Request(tgbotapi.NewDeleteMyCommandsWithScope(scope))
+		if err != nil {
+			return fmt.Errorf("failed to delete commands of %d: %w", userId, err)
+		}
+
+		return nil
+	}
+
+	_, err := bot.telegramApi.Request(tgbotapi.NewSetMyCommandsWithScope(scope, bot.getCommandsForRole(role)...))
+	if err != nil {
+		return fmt.Errorf("failed to set commands of %d: %w", userId, err)
+	}
+
+	return nil
+}
+
+// parseIterationsArgument extracts trailing "2x" or "x2" from command arguments
+func parseIterationsArgument(args string) (string, int) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return args, 1
+	}
+
+	last := fields[len(fields)-1]
+	number := ""
+
+	if strings.HasSuffix(last, "x") {
+		number = strings.TrimSuffix(last, "x")
+	} else if strings.HasPrefix(last, "x") {
+		number = strings.TrimPrefix(last, "x")
+	}
+
+	iterations, err := strconv.Atoi(number)
+	if err != nil || iterations < 1 {
+		return args, 1
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(args), last)), iterations
+}
+
+func (bot *Bot) cleanSegments(segmentIds []string, iterations int) error {
+	properties, err := bot.robotApi.GetMapSegmentationCapabilityProperties()
+	if err != nil {
+		return err
+	}
+
+	if iterations < properties.IterationCount.Min || iterations > properties.IterationCount.Max {
+		return fmt.Errorf("robot supports %d to %d passes", properties.IterationCount.Min, properties.IterationCount.Max)
+	}
+
+	customOrder := properties.CustomOrderSupport && len(segmentIds) > 1
+
+	err = bot.robotApi.CleanMapSegments(segmentIds, iterations, customOrder)
+	if err != nil {
+		return err
+	}
+
+	bot.setSessionTarget(bot.describeSegments(segmentIds))
+
+	return nil
+}
+
+func (bot *Bot) cleanEverything() error {
+	err := bot.robotApi.Start()
+	if err != nil {
+		return err
+	}
+
+	bot.setSessionTarget("Everything")
+
+	return nil
+}
+
+// stopRobot stops the robot, the running session is recorded as stopped
+func (bot *Bot) stopRobot() error {
+	err := bot.robotApi.Stop()
+	if err != nil {
+		return err
+	}
+
+	bot.setSessionInterrupted()
+
+	return nil
+}
+
+// sendRobotHome sends the robot to the dock, the running session is recorded as stopped rather than completed
+func (bot *Bot) sendRobotHome() error {
+	err := bot.robotApi.Home()
+	if err != nil {
+		return err
+	}
+
+	bot.setSessionInterrupted()
+
+	return nil
+}
+
+func formatIterations(iterations int) string {
+	if iterations > 1 {
+		return fmt.Sprintf(" (%dx)", iterations)
+	}
+
+	return ""
 }
 
 func (bot *Bot) handleCleanCommand(requesterId int64, args string) error {
@@ -83,9 +324,16 @@ func (bot *Bot) handleCleanCommand(requesterId int64, args string) error {
 		return err
 	}
 
+	args, iterations := parseIterationsArgument(args)
+
 	if args != "" {
 		if args == "all" {
-			err := bot.robotApi.Start()
+			if iterations > 1 {
+				bot.telegramApi.Send(tgbotapi.NewMessage(requesterId, "❌ Passes can only be set for rooms, use /clean all to clean everything once"))
+				return nil
+			}
+
+			err := bot.cleanEverything()
 			if err != nil {
 				return err
 			}
@@ -96,72 +344,54 @@ func (bot *Bot) handleCleanCommand(requesterId int64, args string) error {
 		}
 
 		roomNames := strings.Split(args, ",")
-		roomsToFind := map[string]bool{}
-
-		for _, roomName := range roomNames {
-			roomsToFind[roomName] = true
-		}
-
 		toClean := []string{}
 
-		for _, layer := range *rooms {
-			if roomsToFind[*layer.Metadata.Name] {
-				toClean = append(toClean, *layer.Metadata.SegmentId)
-				roomsToFind[*layer.Metadata.Name] = false
+		// rooms are cleaned in the order they were specified
+		for _, roomName := range roomNames {
+			roomName = strings.TrimSpace(roomName)
+			found := false
+
+			for _, layer := range *rooms {
+				if *layer.Metadata.Name == roomName {
+					toClean = append(toClean, *layer.Metadata.SegmentId)
+					found = true
+					break
+				}
 			}
-		}
-
-		anyRoomNotFound := false
 
-		for roomName, notFound := range roomsToFind {
-			if notFound {
+			if !found {
 				bot.telegramApi.Send(tgbotapi.NewMessage(requesterId, "❌ Room "+roomName+" not found"))
-				anyRoomNotFound = true
+				return nil
 			}
 		}
 
-		if anyRoomNotFound {
-			return nil
-		}
-
-		err := bot.robotApi.CleanMapSegments(toClean, 1)
+		err := bot.cleanSegments(toClean, iterations)
 		if err != nil {
 			return err
 		}
 
-		bot.telegramApi.Send(tgbotapi.NewMessage(requesterId, "🧹 Cleaning "+strings.Join(roomNames, ", ")))
+		bot.telegramApi.Send(tgbotapi.NewMessage(requesterId, "🧹 Cleaning "+strings.Join(roomNames, ", ")+formatIterations(iterations)))
 
 		return nil
 	}
 
-	keyboardButtons := [][]tgbotapi.InlineKeyboardButton{
-		{tgbotapi.NewInlineKeyboardButtonData("💯 Everything", "clean_all")},
-	}
+	selection := &cleanSelection{Iterations: iterations}
 
-	sort.Slice(*rooms, func(i, j int) bool {
-		return strings.Compare(*((*rooms)[i].Metadata.Name), *((*rooms)[j].Metadata.Name)) < 0
-	})
-
-	for _, layer := range *rooms {
-		keyboardButtons = append(
-			keyboardButtons,
-			[]tgbotapi.InlineKeyboardButton{
-				tgbotapi.NewInlineKeyboardButtonData(
-					*layer.Metadata.Name,
-					fmt.Sprintf("clean %s", *layer.Metadata.SegmentId),
-				),
-			},
-		)
+	keyboard, err := bot.buildRobotCleanKeyboard(selection)
+	if err != nil {
+		return err
 	}
 
 	botMessage := tgbotapi.NewMessage(requesterId, "What do you want to clean?")
 	botMessage.ParseMode = "MarkdownV2"
+	botMessage.ReplyMarkup = keyboard
 
-	botMessage.ReplyMarkup = tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: keyboardButtons,
+	sent, err := bot.telegramApi.Send(botMessage)
+	if err != nil {
+		return err
 	}
 
-	bot.telegramApi.Send(botMessage)
+	bot.storeCleanSelection(sent.Chat.ID, sent.MessageID, selection)
 
 	return nil
 }
@@ -173,9 +403,49 @@ func (bot *Bot) handleStatusCommand(requesterId int64, args string) error {
 		return err
 	}
 
+	fullState, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	live := strings.TrimSpace(args) == "live"
+	if live && !canFollowLive(state) {
+		live = false
+		bot.Send(requesterId, liveStatusDockedMessage)
+	}
+
+	mapImage := bot.renderMap(&fullState.Map)
+	mapMsg := tgbotapi.NewPhoto(requesterId, tgbotapi.FileBytes{
+		Name:  "map.png",
+		Bytes: mapImage,
+	})
+
+	mapMsg.Caption = bot.buildStatusCaption(state)
+	mapMsg.ParseMode = "MarkdownV2"
+	mapMsg.ReplyMarkup = buildStatusKeyboard(state, live)
+
+	sent, err := bot.telegramApi.Send(mapMsg)
+
+	if err != nil {
+		return err
+	}
+
+	if live {
+		bot.startLiveStatus(sent.Chat.ID, sent.MessageID)
+	}
+
+	return nil
+}
+
+// buildStatusCaption describes robot state, the result is formatted as MarkdownV2
+func (bot *Bot) buildStatusCaption(state *CurrentState) string {
 	statusString := robotStatusEmoji(state.Status) + " " + localizeRobotStatus(state.Status)
 
 	stateString := ""
+	if bot.name != "" && len(bot.robots) > 1 {
+		stateString += "*" + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, bot.name) + "*\n"
+	}
+
 	stateString += fmt.Sprintf(
 		"%s\n🔋 *Battery:* %d%% \\(%s\\)",
 		statusString,
@@ -205,6 +475,19 @@ func (bot *Bot) handleStatusCommand(requesterId int64, args string) error {
 		stateString += "\n💧 *Water grade:* " + localizeWaterGrade(state.WaterGrade)
 	}
 
+	if state.DockStatus != "" && state.DockStatus != "idle" {
+		stateString += "\n🏠 *Dock:* " + localizeDockStatus(state.DockStatus)
+	}
+
+	if state.Error != nil && state.Error.Message != "" {
+		stateString += "\n❗ *Error:* " + tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, state.Error.Message)
+	}
+
+	return stateString
+}
+
+// buildStatusKeyboard offers actions that make sense in the current state, live status messages can be stopped
+func buildStatusKeyboard(state *CurrentState, live bool) tgbotapi.InlineKeyboardMarkup {
 	keyboard := tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🧹 Start cleaning", "clean"),
 	)
@@ -231,28 +514,59 @@ func (bot *Bot) handleStatusCommand(requesterId int64, args string) error {
 		)
 	}
 
-	fullState, err := bot.robotApi.GetRobotState()
+	liveRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📡 Live updates", "live start"),
+	)
+
+	if live {
+		liveRow = tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏹ Stop live updates", "live stop"),
+		)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard, liveRow)
+}
+
+// area around the robot shown after locating it, in cm
+const locateSnippetRadius = 150
+
+func (bot *Bot) handleLocateCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("LocateCapability") {
+		return fmt.Errorf("robot doesn't support locate")
+	}
+
+	err := bot.robotApi.Locate()
 	if err != nil {
 		return err
 	}
 
-	mapImage := valetudo_map_renderer.RenderMap(&fullState.Map)
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	robot := state.Map.FindEntity("robot_position")
+	if robot == nil || robot.Points == nil || len(*robot.Points) < 2 {
+		return bot.Send(requesterId, "🔊 Robot is playing sound, but its position on the map is unknown")
+	}
+
+	options := bot.getMapRenderOptions()
+	options.Scale = 4
+	options.Crop = &valetudo_map_renderer.MapCrop{
+		X:      (*robot.Points)[0],
+		Y:      (*robot.Points)[1],
+		Radius: locateSnippetRadius,
+	}
+
 	mapMsg := tgbotapi.NewPhoto(requesterId, tgbotapi.FileBytes{
 		Name:  "map.png",
-		Bytes: mapImage,
+		Bytes: valetudo_map_renderer.RenderMapWithOptions(&state.Map, options),
 	})
-
-	mapMsg.Caption = stateString
-	mapMsg.ParseMode = "MarkdownV2"
-	mapMsg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard)
+	mapMsg.Caption = "🔊 I'm here!"
 
 	_, err = bot.telegramApi.Send(mapMsg)
 
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
 func (bot *Bot) handleModeCommand(requesterId int64, args string) error {
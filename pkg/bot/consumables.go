@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SetConsumableThresholds applies to all robots added so far and the ones added later
+func (bot *Bot) SetConsumableThresholds(percent int, minutes int) {
+	for _, robot := range bot.robots {
+		robot.consumablePercentThreshold = percent
+		robot.consumableMinutesThreshold = minutes
+	}
+}
+
+func (bot *Bot) isConsumableLow(consumable CurrentStateConsumable) bool {
+	switch consumable.Unit {
+	case "percent":
+		return consumable.Value <= bot.consumablePercentThreshold
+	case "minutes":
+		return consumable.Value <= bot.consumableMinutesThreshold
+	}
+
+	return false
+}
+
+func (bot *Bot) handleConsumablesChange(previous *CurrentState, new *CurrentState) {
+	for _, consumable := range new.Consumables {
+		if !bot.isConsumableLow(consumable) {
+			continue
+		}
+
+		// only notify when the threshold is crossed, not on every update
+		wasLow := true
+		for _, previousConsumable := range previous.Consumables {
+			if previousConsumable.Type == consumable.Type && previousConsumable.SubType == consumable.SubType {
+				wasLow = bot.isConsumableLow(previousConsumable)
+				break
+			}
+		}
+
+		if wasLow {
+			continue
+		}
+
+		message := fmt.Sprintf(
+			"🧰 %s is running low (%s left), check /consumables",
+			localizeConsumable(consumable.Type, consumable.SubType),
+			formatConsumableRemaining(consumable.Value, consumable.Unit),
+		)
+
+		bot.notify(notificationConsumables, message, nil)
+	}
+}
+
+func (bot *Bot) getConsumables() ([]CurrentStateConsumable, error) {
+	attributes, err := bot.robotApi.GetConsumables()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []CurrentStateConsumable{}
+	for _, attribute := range *attributes {
+		if consumable := attributeToConsumable(attribute); consumable != nil {
+			result = append(result, *consumable)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+
+		return result[i].SubType < result[j].SubType
+	})
+
+	return result, nil
+}
+
+func (bot *Bot) buildConsumablesMessage() (string, tgbotapi.InlineKeyboardMarkup, error) {
+	consumables, err := bot.getConsumables()
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := "🧰 Consumables:"
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, consumable := range consumables {
+		icon := "🟢"
+		if bot.isConsumableLow(consumable) {
+			icon = "🔴"
+		}
+
+		name := localizeConsumable(consumable.Type, consumable.SubType)
+		text += fmt.Sprintf("\n%s %s: %s", icon, name, formatConsumableRemaining(consumable.Value, consumable.Unit))
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Reset "+name, "consumable reset "+consumable.Type+" "+consumable.SubType),
+		))
+	}
+
+	if len(consumables) == 0 {
+		text = "🧰 Robot doesn't report any consumables"
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) handleConsumablesCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("ConsumableMonitoringCapability") {
+		return fmt.Errorf("robot doesn't support consumable monitoring")
+	}
+
+	text, keyboard, err := bot.buildConsumablesMessage()
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	if len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
+	}
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) refreshConsumablesMessage(query *tgbotapi.CallbackQuery) error {
+	text, keyboard, err := bot.buildConsumablesMessage()
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+	_, err = bot.telegramApi.Request(edit)
+
+	return err
+}
+
+func (bot *Bot) handleConsumableCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch args[0] {
+	case "reset":
+		if len(args) < 3 {
+			return nil
+		}
+
+		name := localizeConsumable(args[1], args[2])
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Yes, reset "+name, "consumable confirm "+args[1]+" "+args[2]),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("↩️ Cancel", "consumable cancel"),
+			),
+		)
+
+		_, err := bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, keyboard))
+
+		return err
+
+	case "confirm":
+		if len(args) < 3 {
+			return nil
+		}
+
+		err := bot.robotApi.ResetConsumable(args[1], args[2])
+		if err != nil {
+			return err
+		}
+
+		callback := tgbotapi.NewCallback(query.ID, "🔄 "+localizeConsumable(args[1], args[2])+" reset")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			log.Println(err)
+		}
+
+		return bot.refreshConsumablesMessage(query)
+
+	case "cancel":
+		return bot.refreshConsumablesMessage(query)
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// manual control is disabled when no button was pressed for this long
+const driveInactivityTimeout = 60 * time.Second
+
+// high resolution control expects continuous commands, one button press streams them for a while
+const driveStreamInterval = 200 * time.Millisecond
+const driveStreamDuration = time.Second
+
+type driveSession struct {
+	chatId         int64
+	messageId      int
+	highResolution bool
+	timer          *time.Timer
+
+	/** incremented on every button press so running movement streams know they should stop */
+	generation int
+}
+
+// high resolution vectors for the joystick buttons, velocity is -1 to 1 and angle -180 to 180
+var driveVectors = map[string][2]float64{
+	"forward":  {0.5, 0},
+	"backward": {-0.5, 0},
+	"left":     {0.2, -90},
+	"right":    {0.2, 90},
+}
+
+var driveMovementCommands = map[string]string{
+	"forward":  "forward",
+	"backward": "backward",
+	"left":     "rotate_counterclockwise",
+	"right":    "rotate_clockwise",
+}
+
+func buildDriveKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬆️", "drive forward"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↪️", "drive left"),
+			tgbotapi.NewInlineKeyboardButtonData("⏹", "drive stop"),
+			tgbotapi.NewInlineKeyboardButtonData("↩️", "drive right"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬇️", "drive backward"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Exit manual control", "drive exit"),
+		),
+	)
+}
+
+func (bot *Bot) setManualControlEnabled(highResolution bool, enabled bool) error {
+	if highResolution {
+		return bot.robotApi.SetHighResolutionManualControlEnabled(enabled)
+	}
+
+	return bot.robotApi.SetManualControlEnabled(enabled)
+}
+
+func (bot *Bot) handleDriveCommand(requesterId int64, args string) error {
+	highResolution := bot.HasCapability("HighResolutionManualControlCapability")
+	if !highResolution && !bot.HasCapability("ManualControlCapability") {
+		return fmt.Errorf("robot doesn't support manual control")
+	}
+
+	// only one joystick can be active at a time
+	bot.endDriveSession("🕹 Manual control was taken over by another message")
+
+	err := bot.setManualControlEnabled(highResolution, true)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, "🕹 Manual control enabled")
+	msg.ReplyMarkup = buildDriveKeyboard()
+
+	sent, err := bot.telegramApi.Send(msg)
+	if err != nil {
+		bot.setManualControlEnabled(highResolution, false)
+		return err
+	}
+
+	bot.driveMutex.Lock()
+	defer bot.driveMutex.Unlock()
+
+	bot.drive = &driveSession{
+		chatId:         sent.Chat.ID,
+		messageId:      sent.MessageID,
+		highResolution: highResolution,
+		timer: time.AfterFunc(driveInactivityTimeout, func() {
+			bot.endDriveSession("🕹 Manual control disabled after inactivity")
+		}),
+	}
+
+	return nil
+}
+
+// endDriveSession disables manual control and removes the joystick keyboard
+func (bot *Bot) endDriveSession(message string) {
+	bot.driveMutex.Lock()
+	session := bot.drive
+	bot.drive = nil
+	bot.driveMutex.Unlock()
+
+	if session == nil {
+		return
+	}
+
+	session.timer.Stop()
+
+	err := bot.setManualControlEnabled(session.highResolution, false)
+	if err != nil {
+		log.Println(err)
+	}
+
+	edit := tgbotapi.NewEditMessageText(session.chatId, session.messageId, message)
+	if _, err := bot.telegramApi.Request(edit); err != nil {
+		log.Println(err)
+	}
+}
+
+func (bot *Bot) handleDriveCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	bot.driveMutex.Lock()
+	session := bot.drive
+
+	if session == nil || session.chatId != query.Message.Chat.ID || session.messageId != query.Message.MessageID {
+		bot.driveMutex.Unlock()
+
+		_, err := bot.telegramApi.Request(tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, "🕹 Manual control is not active, use /drive"))
+
+		return err
+	}
+
+	session.timer.Reset(driveInactivityTimeout)
+	session.generation++
+	generation := session.generation
+	bot.driveMutex.Unlock()
+
+	action := args[0]
+
+	switch action {
+	case "exit":
+		bot.endDriveSession("🕹 Manual control ended")
+		return nil
+
+	case "stop":
+		if session.highResolution {
+			return bot.robotApi.HighResolutionManualControlMove(0, 0)
+		}
+
+		return nil
+	}
+
+	if !session.highResolution {
+		command, ok := driveMovementCommands[action]
+		if !ok {
+			return nil
+		}
+
+		return bot.robotApi.ManualControlMove(command)
+	}
+
+	vector, ok := driveVectors[action]
+	if !ok {
+		return nil
+	}
+
+	go bot.streamDriveVector(session, generation, vector[0], vector[1])
+
+	return nil
+}
+
+// streamDriveVector keeps sending the movement until it runs out or another button is pressed
+func (bot *Bot) streamDriveVector(session *driveSession, generation int, velocity float64, angle float64) {
+	for elapsed := time.Duration(0); elapsed < driveStreamDuration; elapsed += driveStreamInterval {
+		bot.driveMutex.Lock()
+		active := bot.drive == session && session.generation == generation
+		bot.driveMutex.Unlock()
+
+		if !active {
+			return
+		}
+
+		err := bot.robotApi.HighResolutionManualControlMove(velocity, angle)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		time.Sleep(driveStreamInterval)
+	}
+}
@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const forwardedEventsStorageKey = "forwarded_events"
+
+// how often valetudo is asked for new events
+const eventsPollInterval = 30 * time.Second
+
+// forwardedEventMessage is a copy of event sent to one of the chats, kept so buttons can be removed everywhere once handled
+type forwardedEventMessage struct {
+	ChatId    int64 `json:"chatId"`
+	MessageId int   `json:"messageId"`
+}
+
+type forwardedEvent struct {
+	Text     string                  `json:"text"`
+	Messages []forwardedEventMessage `json:"messages"`
+}
+
+func describeEvent(event valetudo.ValetudoEvent) string {
+	switch event.Class {
+	case valetudo.DustBinFullEventClass:
+		return "🗑 Dustbin is full, please empty it"
+	case valetudo.ConsumableDepletedEventClass:
+		return "🧰 " + localizeConsumable(event.Type, event.SubType) + " is depleted"
+	case valetudo.MopAttachmentReminderEventClass:
+		return "🧽 Mop is still attached, remove it if you don't want to mop"
+	case valetudo.PendingMapChangeEventClass:
+		return "🗺 Robot wants to replace the map with a new one"
+	case valetudo.MissingResourceEventClass:
+		return "⚠️ Missing resource: " + event.Message
+	}
+
+	return "🔔 " + event.Class
+}
+
+func getEventNotificationCategory(event valetudo.ValetudoEvent) string {
+	switch event.Class {
+	case valetudo.ConsumableDepletedEventClass, valetudo.DustBinFullEventClass:
+		return notificationConsumables
+	}
+
+	return notificationEvents
+}
+
+func buildEventKeyboard(event valetudo.ValetudoEvent) tgbotapi.InlineKeyboardMarkup {
+	switch event.Class {
+	case valetudo.PendingMapChangeEventClass:
+		return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Accept", "event "+valetudo.EventInteractionYes+" "+event.Id),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Reject", "event "+valetudo.EventInteractionNo+" "+event.Id),
+		))
+	case valetudo.ConsumableDepletedEventClass:
+		return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Reset consumable", "event "+valetudo.EventInteractionReset+" "+event.Id),
+			tgbotapi.NewInlineKeyboardButtonData("👌 Dismiss", "event "+valetudo.EventInteractionOk+" "+event.Id),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("👌 Dismiss", "event "+valetudo.EventInteractionOk+" "+event.Id),
+	))
+}
+
+func localizeEventInteraction(interaction string) string {
+	switch interaction {
+	case valetudo.EventInteractionOk:
+		return "Dismissed"
+	case valetudo.EventInteractionYes:
+		return "Accepted"
+	case valetudo.EventInteractionNo:
+		return "Rejected"
+	case valetudo.EventInteractionReset:
+		return "Reset"
+	}
+
+	return interaction
+}
+
+func (bot *Bot) getForwardedEvents() (map[string]forwardedEvent, error) {
+	events := map[string]forwardedEvent{}
+
+	_, err := bot.storage.Get(forwardedEventsStorageKey, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// runEventsPolling forwards unprocessed valetudo events to users until the bot exits
+func (bot *Bot) runEventsPolling() {
+	for {
+		err := bot.pollEvents()
+		if err != nil {
+			log.Println(fmt.Errorf("failed to check events: %w", err))
+		}
+
+		time.Sleep(eventsPollInterval)
+	}
+}
+
+func (bot *Bot) pollEvents() error {
+	events, err := bot.robotApi.GetEvents()
+	if err != nil {
+		return err
+	}
+
+	bot.eventsMutex.Lock()
+	defer bot.eventsMutex.Unlock()
+
+	forwarded, err := bot.getForwardedEvents()
+	if err != nil {
+		return err
+	}
+
+	pending := map[string]bool{}
+	changed := false
+
+	for _, event := range *events {
+		// errors are already reported from robot state by handleError
+		if event.Processed || event.Class == valetudo.ErrorStateEventClass {
+			continue
+		}
+
+		pending[event.Id] = true
+
+		if _, ok := forwarded[event.Id]; ok {
+			continue
+		}
+
+		forwarded[event.Id] = bot.forwardEvent(event)
+		changed = true
+	}
+
+	// handled in valetudo directly or by someone else
+	for id, event := range forwarded {
+		if !pending[id] {
+			bot.closeForwardedEvent(event, "✔️ Handled")
+			delete(forwarded, id)
+			changed = true
+		}
+	}
+
+	// events are polled often, storage is rewritten only when there's something new
+	if !changed {
+		return nil
+	}
+
+	return bot.storage.Set(forwardedEventsStorageKey, forwarded)
+}
+
+// forwardEvent sends the event to every user who wants it, held copies lose their buttons
+func (bot *Bot) forwardEvent(event valetudo.ValetudoEvent) forwardedEvent {
+	result := forwardedEvent{
+		Text:     bot.labelNotification(describeEvent(event)),
+		Messages: []forwardedEventMessage{},
+	}
+
+	category := getEventNotificationCategory(event)
+
+	for _, user := range bot.getUserIds() {
+		deliver, silent, err := bot.getNotificationDelivery(user, category, result.Text)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if !deliver {
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(user, result.Text)
+		msg.ReplyMarkup = buildEventKeyboard(event)
+		msg.DisableNotification = silent
+
+		sent, err := bot.sendNotification(msg)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		result.Messages = append(result.Messages, forwardedEventMessage{
+			ChatId:    sent.Chat.ID,
+			MessageId: sent.MessageID,
+		})
+	}
+
+	return result
+}
+
+// closeForwardedEvent removes buttons from every copy of the event and appends the resolution
+func (bot *Bot) closeForwardedEvent(event forwardedEvent, resolution string) {
+	for _, message := range event.Messages {
+		_, err := bot.telegramApi.Request(tgbotapi.NewEditMessageText(message.ChatId, message.MessageId, event.Text+"\n\n"+resolution))
+		if err != nil {
+			log.Println(fmt.Errorf("failed to close event message: %w", err))
+		}
+	}
+}
+
+func (bot *Bot) handleEventCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) < 2 {
+		return nil
+	}
+
+	interaction := args[0]
+	id := args[1]
+
+	err := bot.robotApi.InteractWithEvent(id, interaction)
+	if err != nil {
+		return err
+	}
+
+	resolution := "✔️ " + localizeEventInteraction(interaction)
+	if query.From != nil {
+		resolution += " by " + describeTelegramUser(query.From)
+	}
+
+	bot.eventsMutex.Lock()
+	forwarded, err := bot.getForwardedEvents()
+	if err == nil {
+		event, ok := forwarded[id]
+		if !ok {
+			// message left over from before the event was forgotten
+			event = forwardedEvent{
+				Text:     query.Message.Text,
+				Messages: []forwardedEventMessage{{ChatId: query.Message.Chat.ID, MessageId: query.Message.MessageID}},
+			}
+		}
+
+		bot.closeForwardedEvent(event, resolution)
+		delete(forwarded, id)
+
+		err = bot.storage.Set(forwardedEventsStorageKey, forwarded)
+	}
+	bot.eventsMutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewCallback(query.ID, resolution))
+
+	return err
+}
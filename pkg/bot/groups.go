@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const notificationTopicsStorageKey = "notification_topics"
+
+// receivedUpdate is telegram update extended with fields the telegram library doesn't know about
+type receivedUpdate struct {
+	tgbotapi.Update
+
+	/** forum topic the message was sent to, 0 outside of topics */
+	ThreadId int
+}
+
+type topicMessageFields struct {
+	MessageThreadId int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+}
+
+type updateTopicFields struct {
+	Message       *topicMessageFields `json:"message"`
+	CallbackQuery *struct {
+		Message *topicMessageFields `json:"message"`
+	} `json:"callback_query"`
+}
+
+func (fields updateTopicFields) getThreadId() int {
+	message := fields.Message
+	if fields.CallbackQuery != nil {
+		message = fields.CallbackQuery.Message
+	}
+
+	if message == nil || !message.IsTopicMessage {
+		return 0
+	}
+
+	return message.MessageThreadId
+}
+
+// getUpdatesChan long polls for updates the same way as tgbotapi.GetUpdatesChan, but keeps forum topic of messages
+func (bot *Bot) getUpdatesChan(timeout int) <-chan receivedUpdate {
+	updates := make(chan receivedUpdate, 100)
+
+	go func() {
+		offset := 0
+
+		for {
+			params := tgbotapi.Params{}
+			params.AddNonZero("offset", offset)
+			params.AddNonZero("timeout", timeout)
+
+			response, err := bot.telegramApi.MakeRequest("getUpdates", params)
+			if err != nil {
+				log.Println(fmt.Errorf("failed to get updates, retrying in 3 seconds: %w", err))
+				time.Sleep(3 * time.Second)
+
+				continue
+			}
+
+			items := []json.RawMessage{}
+			err = json.Unmarshal(response.Result, &items)
+			if err != nil {
+				log.Println(fmt.Errorf("failed to parse updates: %w", err))
+				continue
+			}
+
+			for _, item := range items {
+				update := receivedUpdate{}
+				err := json.Unmarshal(item, &update.Update)
+				if err != nil {
+					log.Println(fmt.Errorf("failed to parse update: %w", err))
+					continue
+				}
+
+				if update.UpdateID >= offset {
+					offset = update.UpdateID + 1
+				}
+
+				fields := updateTopicFields{}
+				if err := json.Unmarshal(item, &fields); err == nil {
+					update.ThreadId = fields.getThreadId()
+				}
+
+				updates <- update
+			}
+		}
+	}()
+
+	return updates
+}
+
+// getEffectiveRole returns role of the chat, in groups allowed as a whole members can use their own role when it's higher,
+// personal roles alone don't allow anything in groups, otherwise adding the bot to any group would expose it there
+func (bot *Bot) getEffectiveRole(chat *tgbotapi.Chat, from *tgbotapi.User) userRole {
+	role := bot.getUserRole(chat.ID)
+
+	if role == "" || chat.IsPrivate() || from == nil {
+		return role
+	}
+
+	if memberRole := bot.getUserRole(from.ID); roleLevels[memberRole] > roleLevels[role] {
+		role = memberRole
+	}
+
+	return role
+}
+
+// isCommandForMe filters out /command@otherbot sent to groups with multiple bots, /command@robot is for us
+func (bot *Bot) isCommandForMe(message *tgbotapi.Message) bool {
+	_, target, found := strings.Cut(message.CommandWithAt(), "@")
+
+	return !found || strings.EqualFold(target, bot.telegramApi.Self.UserName) || bot.robotByName(target) != nil
+}
+
+func (bot *Bot) getNotificationTopics() (map[int64]int, error) {
+	topics := map[int64]int{}
+
+	_, err := bot.sharedStorage.Get(notificationTopicsStorageKey, &topics)
+	if err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// sendNotification sends message that isn't a reply to a command, into the notification topic of the chat if there's one
+func (bot *Bot) sendNotification(msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	topics, err := bot.getNotificationTopics()
+	if err != nil {
+		log.Println(err)
+	}
+
+	threadId := topics[msg.ChatID]
+	if threadId != 0 {
+		sent, err := bot.telegramApi.SendToThread(msg, threadId)
+		if err == nil {
+			return sent, nil
+		}
+
+		// topic was probably deleted, general topic is better than nothing
+		log.Println(fmt.Errorf("failed to send notification to topic %d: %w", threadId, err))
+	}
+
+	return bot.telegramApi.SendToThread(msg, 0)
+}
+
+// sendNotificationPhoto is sendNotification for messages with image
+func (bot *Bot) sendNotificationPhoto(msg tgbotapi.PhotoConfig) error {
+	topics, err := bot.getNotificationTopics()
+	if err != nil {
+		log.Println(err)
+	}
+
+	threadId := topics[msg.ChatID]
+	if threadId != 0 {
+		_, err = bot.telegramApi.SendToThread(msg, threadId)
+		if err == nil {
+			return nil
+		}
+
+		log.Println(fmt.Errorf("failed to send notification to topic %d: %w", threadId, err))
+	}
+
+	_, err = bot.telegramApi.SendToThread(msg, 0)
+
+	return err
+}
+
+// setReplyThread makes messages sent to the chat go to the topic of the update being handled, 0 sends them to the general topic again
+func (bot *Bot) setReplyThread(chatId int64, threadId int) {
+	bot.replyThreadsMutex.Lock()
+	defer bot.replyThreadsMutex.Unlock()
+
+	if threadId == 0 {
+		delete(bot.replyThreads, chatId)
+	} else {
+		bot.replyThreads[chatId] = threadId
+	}
+}
+
+func (bot *Bot) getReplyThread(chatId int64) int {
+	bot.replyThreadsMutex.Lock()
+	defer bot.replyThreadsMutex.Unlock()
+
+	return bot.replyThreads[chatId]
+}
+
+// threadRequest builds request for message sent to a forum topic, the telegram library doesn't support topics
+func threadRequest(c tgbotapi.Chattable, threadId int) (string, tgbotapi.Params, []tgbotapi.RequestFile, error) {
+	params := tgbotapi.Params{}
+	params.AddNonZero("message_thread_id", threadId)
+
+	addBaseChat := func(chat tgbotapi.BaseChat) error {
+		params.AddNonZero64("chat_id", chat.ChatID)
+		params.AddNonZero("reply_to_message_id", chat.ReplyToMessageID)
+		params.AddBool("disable_notification", chat.DisableNotification)
+
+		return params.AddInterface("reply_markup", chat.ReplyMarkup)
+	}
+
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		params.AddNonEmpty("text", config.Text)
+		params.AddNonEmpty("parse_mode", config.ParseMode)
+		params.AddBool("disable_web_page_preview", config.DisableWebPagePreview)
+
+		return "sendMessage", params, nil, addBaseChat(config.BaseChat)
+
+	case tgbotapi.PhotoConfig:
+		params.AddNonEmpty("caption", config.Caption)
+		params.AddNonEmpty("parse_mode", config.ParseMode)
+
+		return "sendPhoto", params, []tgbotapi.RequestFile{{Name: "photo", Data: config.File}}, addBaseChat(config.BaseChat)
+	}
+
+	return "", nil, nil, fmt.Errorf("messages of type %T can't be sent to topics", c)
+}
+
+func getChattableChatId(c tgbotapi.Chattable) int64 {
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		return config.ChatID
+	case tgbotapi.PhotoConfig:
+		return config.ChatID
+	}
+
+	return 0
+}
+
+func (bot *Bot) handleTopicCommand(chatId int64, threadId int, args string) error {
+	topics, err := bot.getNotificationTopics()
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(args) == "off" {
+		delete(topics, chatId)
+
+		err = bot.sharedStorage.Set(notificationTopicsStorageKey, topics)
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(chatId, "🧵 Notifications will be sent to the general topic")
+	}
+
+	if threadId == 0 {
+		return bot.Send(chatId, "Send /topic inside a forum topic to receive notifications there, /topic off sends them to the general topic again")
+	}
+
+	topics[chatId] = threadId
+
+	err = bot.sharedStorage.Set(notificationTopicsStorageKey, topics)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.sendNotification(tgbotapi.NewMessage(chatId, "🧵 Notifications will be sent to this topic"))
+
+	return err
+}
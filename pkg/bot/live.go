@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// live status message is edited at most this often, telegram doesn't like frequent edits
+const liveStatusInterval = 10 * time.Second
+
+// live updates are stopped after this time even if the robot didn't dock
+const liveStatusMaxDuration = 3 * time.Hour
+
+const liveStatusDockedMessage = "🤖 Robot is docked, there's nothing to follow"
+
+type liveStatus struct {
+	chatId    int64
+	messageId int
+	cancel    context.CancelFunc
+
+	/** newest map received from the robot that wasn't shown yet */
+	mapMutex  sync.Mutex
+	latestMap *valetudo.RobotStateMap
+}
+
+func (live *liveStatus) setMap(robotMap *valetudo.RobotStateMap) {
+	live.mapMutex.Lock()
+	defer live.mapMutex.Unlock()
+
+	live.latestMap = robotMap
+}
+
+// takeMap returns map received since the last call, nil if there's none
+func (live *liveStatus) takeMap() *valetudo.RobotStateMap {
+	live.mapMutex.Lock()
+	defer live.mapMutex.Unlock()
+
+	robotMap := live.latestMap
+	live.latestMap = nil
+
+	return robotMap
+}
+
+func (bot *Bot) setLatestState(state *CurrentState) {
+	bot.latestStateMutex.Lock()
+	defer bot.latestStateMutex.Unlock()
+
+	bot.latestState = state
+}
+
+// getLatestState returns state from the attributes stream, the robot is asked directly only before the stream starts
+func (bot *Bot) getLatestState() (*CurrentState, error) {
+	bot.latestStateMutex.Lock()
+	state := bot.latestState
+	bot.latestStateMutex.Unlock()
+
+	if state != nil {
+		return state, nil
+	}
+
+	return bot.getParsedState()
+}
+
+// canFollowLive tells if live status makes sense, docked robot won't move
+func canFollowLive(state *CurrentState) bool {
+	return state.Status != "docked"
+}
+
+// startLiveStatus keeps editing the status message until the robot docks, one live message per chat
+func (bot *Bot) startLiveStatus(chatId int64, messageId int) {
+	ctx, cancel := context.WithTimeout(context.Background(), liveStatusMaxDuration)
+	live := &liveStatus{
+		chatId:    chatId,
+		messageId: messageId,
+		cancel:    cancel,
+	}
+
+	bot.liveMutex.Lock()
+	previous := bot.liveStatuses[chatId]
+	bot.liveStatuses[chatId] = live
+	bot.liveMutex.Unlock()
+
+	if previous != nil {
+		previous.cancel()
+	}
+
+	go func() {
+		err := bot.robotApi.ListenToMapChanges(ctx, func(robotMap *valetudo.RobotStateMap, err error) {
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			live.setMap(robotMap)
+		})
+
+		if err != nil && ctx.Err() == nil {
+			log.Println(fmt.Errorf("failed to listen to map changes: %w", err))
+		}
+	}()
+
+	go bot.runLiveStatus(ctx, live)
+}
+
+// stopLiveStatus stops updating the message, returns false if the message isn't being updated
+func (bot *Bot) stopLiveStatus(chatId int64, messageId int) bool {
+	bot.liveMutex.Lock()
+	live := bot.liveStatuses[chatId]
+	bot.liveMutex.Unlock()
+
+	if live == nil || live.messageId != messageId {
+		return false
+	}
+
+	live.cancel()
+
+	return true
+}
+
+func (bot *Bot) runLiveStatus(ctx context.Context, live *liveStatus) {
+	ticker := time.NewTicker(liveStatusInterval)
+	defer ticker.Stop()
+
+	lastCaption := ""
+
+	for {
+		select {
+		case <-ctx.Done():
+			bot.finishLiveStatus(live)
+			return
+		case <-ticker.C:
+		}
+
+		state, err := bot.getLatestState()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if !canFollowLive(state) {
+			live.cancel()
+			continue
+		}
+
+		caption := bot.buildStatusCaption(state)
+		keyboard := buildStatusKeyboard(state, true)
+		robotMap := live.takeMap()
+
+		if robotMap == nil && caption == lastCaption {
+			continue
+		}
+
+		lastCaption = caption
+
+		err = bot.editLiveStatus(live, robotMap, caption, keyboard)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to update live status: %w", err))
+		}
+	}
+}
+
+// editLiveStatus replaces caption of the message and its photo if there's a new map
+func (bot *Bot) editLiveStatus(live *liveStatus, robotMap *valetudo.RobotStateMap, caption string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	baseEdit := tgbotapi.BaseEdit{
+		ChatID:      live.chatId,
+		MessageID:   live.messageId,
+		ReplyMarkup: &keyboard,
+	}
+
+	if robotMap == nil {
+		_, err := bot.telegramApi.Request(tgbotapi.EditMessageCaptionConfig{
+			BaseEdit:  baseEdit,
+			Caption:   caption,
+			ParseMode: "MarkdownV2",
+		})
+
+		return err
+	}
+
+	media := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{
+		Name:  "map.png",
+		Bytes: bot.renderMap(robotMap),
+	})
+	media.Caption = caption
+	media.ParseMode = "MarkdownV2"
+
+	_, err := bot.telegramApi.Request(tgbotapi.EditMessageMediaConfig{
+		BaseEdit: baseEdit,
+		Media:    media,
+	})
+
+	return err
+}
+
+// finishLiveStatus shows the final state and removes the stop button
+func (bot *Bot) finishLiveStatus(live *liveStatus) {
+	bot.liveMutex.Lock()
+	if bot.liveStatuses[live.chatId] == live {
+		delete(bot.liveStatuses, live.chatId)
+	}
+	bot.liveMutex.Unlock()
+
+	state, err := bot.getLatestState()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	keyboard := buildStatusKeyboard(state, false)
+
+	err = bot.editLiveStatus(live, live.takeMap(), bot.buildStatusCaption(state)+"\n_Live updates ended_", keyboard)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to finish live status: %w", err))
+	}
+}
+
+func (bot *Bot) handleLiveCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	response := ""
+
+	switch args[0] {
+	case "start":
+		state, err := bot.getParsedState()
+		if err != nil {
+			return err
+		}
+
+		if !canFollowLive(state) {
+			response = liveStatusDockedMessage
+			break
+		}
+
+		bot.startLiveStatus(query.Message.Chat.ID, query.Message.MessageID)
+		response = "📡 Live updates started"
+
+		_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, buildStatusKeyboard(state, true)))
+		if err != nil {
+			return err
+		}
+
+	case "stop":
+		response = "⏹ Live updates stopped"
+
+		// message left over from before restart
+		if !bot.stopLiveStatus(query.Message.Chat.ID, query.Message.MessageID) {
+			state, err := bot.getParsedState()
+			if err != nil {
+				return err
+			}
+
+			_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, buildStatusKeyboard(state, false)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := bot.telegramApi.Request(tgbotapi.NewCallback(query.ID, response))
+
+	return err
+}
@@ -1,5 +1,12 @@
 package bot
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+)
+
 func localizeAttachmentType(attachmentType string) string {
 	switch attachmentType {
 	case "mop":
@@ -55,6 +62,69 @@ func robotStatusEmoji(robotStatus string) string {
 	return "🤖"
 }
 
+func localizeErrorSubsystem(subsystem string) string {
+	switch subsystem {
+	case "core":
+		return "Core"
+	case "power":
+		return "Power"
+	case "sensors":
+		return "Sensors"
+	case "motors":
+		return "Motors"
+	case "navigation":
+		return "Navigation"
+	case "attachments":
+		return "Attachments"
+	case "docking":
+		return "Docking"
+	}
+
+	return "Unknown"
+}
+
+func localizeErrorSeverity(severity valetudo.ValetudoRobotErrorSeverity) string {
+	level := severity.Level
+	switch severity.Level {
+	case "info":
+		level = "ℹ️ Info"
+	case "warning":
+		level = "⚠️ Warning"
+	case "error":
+		level = "❗ Error"
+	case "catastrophic":
+		level = "🔥 Catastrophic"
+	}
+
+	switch severity.Kind {
+	case "transient":
+		return level + ", should go away once resolved"
+	case "permanent":
+		return level + ", needs attention"
+	}
+
+	return level
+}
+
+func localizeDockStatus(status string) string {
+	switch status {
+	case "error":
+		return "Error"
+	case "idle":
+		return "Idle"
+	case "pause":
+		return "Paused"
+	case "emptying":
+		return "Emptying dustbin"
+	case "cleaning":
+		return "Washing mop"
+	case "drying":
+		return "Drying mop"
+	}
+
+	return status
+}
+
 func localizeOperationMode(mode string) string {
 	switch mode {
 	case "vacuum":
@@ -108,3 +178,54 @@ func localizeWaterGrade(usage string) string {
 
 	return usage
 }
+
+func localizeConsumable(consumableType string, subType string) string {
+	name := consumableType
+
+	switch consumableType {
+	case "brush":
+		name = "Brush"
+	case "filter":
+		name = "Filter"
+	case "mop":
+		name = "Mop"
+	case "sensor":
+		name = "Sensors"
+	case "detergent":
+		name = "Detergent"
+	case "bin":
+		name = "Bin"
+	case "cleaning":
+		name = "Cleaning"
+	}
+
+	switch subType {
+	case "main":
+		return "Main " + strings.ToLower(name)
+	case "secondary":
+		return "Secondary " + strings.ToLower(name)
+	case "side_left":
+		return "Left side " + strings.ToLower(name)
+	case "side_right":
+		return "Right side " + strings.ToLower(name)
+	case "dock":
+		return "Dock " + strings.ToLower(name)
+	}
+
+	return name
+}
+
+func formatConsumableRemaining(value int, unit string) string {
+	switch unit {
+	case "percent":
+		return fmt.Sprintf("%d %%", value)
+	case "minutes":
+		if value >= 60 {
+			return fmt.Sprintf("%dh %dm", value/60, value%60)
+		}
+
+		return fmt.Sprintf("%dm", value)
+	}
+
+	return fmt.Sprintf("%d %s", value, unit)
+}
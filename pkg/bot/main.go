@@ -3,37 +3,130 @@ package bot
 import (
 	"fmt"
 	"log"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/storage"
 	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Bot controls one robot, robots served by the same telegram bot share users and notification settings through botShared
 type Bot struct {
+	*botShared
+
+	/** name the robot is selected by, empty when there's only one robot */
+	name        string
 	robotApi    *valetudo.ValetudoClient
-	telegramApi *tgbotapi.BotAPI
-	chatIds     []int64
+	telegramApi *robotTelegramApi
+	/** data of this robot, shared data is in sharedStorage */
+	storage storage.Storage
 
 	/** capabilities supported by the robot */
 	capabilities []string
+
+	/** selections of room picker messages, see cleanSelection */
+	cleanSelections map[string]*cleanSelection
+
+	/** timers being created using /timers, by chat id */
+	timerDrafts map[int64]*timerDraft
+
+	/** active manual control joystick, see drive.go */
+	drive      *driveSession
+	driveMutex sync.Mutex
+
+	/** bot side schedule, see scheduler.go */
+	scheduleMutex      sync.Mutex
+	scheduleMinBattery int
+	timezone           *time.Location
+
+	/** newest state received from the attributes stream, nil until the first one arrives */
+	latestState      *CurrentState
+	latestStateMutex sync.Mutex
+
+	/** status messages being edited as the robot moves, by chat id, see live.go */
+	liveStatuses map[int64]*liveStatus
+	liveMutex    sync.Mutex
+
+	/** cleaning requested through the bot, used to label the next session, see sessions.go */
+	sessionMutex    sync.Mutex
+	sessionTarget   string
+	sessionTargetAt time.Time
+	/** when the user last stopped the robot or sent it home, so the session isn't reported as completed */
+	sessionInterruptedAt time.Time
+
+	/** guards forwarded valetudo events, see events.go */
+	eventsMutex sync.Mutex
+
+	/** consumables at or below these values trigger a notification */
+	consumablePercentThreshold int
+	consumableMinutesThreshold int
 }
 
-func NewBot(robotApi *valetudo.ValetudoClient, telegramApi *tgbotapi.BotAPI) Bot {
-	return Bot{robotApi: robotApi, telegramApi: telegramApi}
+// NewBot creates bot for a single robot, more robots can be added using AddRobot
+func NewBot(robotApi *valetudo.ValetudoClient, telegramApi *tgbotapi.BotAPI, storage storage.Storage) *Bot {
+	shared := &botShared{
+		telegramApi:    telegramApi,
+		sharedStorage:  storage,
+		userRoles:      map[int64]userRole{},
+		pendingPrompts: map[promptKey]pendingPrompt{},
+		replyThreads:   map[int64]int{},
+	}
+
+	bot := newRobotBot(shared, "", robotApi, storage)
+	bot.scheduleMinBattery = 20
+	bot.timezone = time.Local
+	bot.consumablePercentThreshold = 10
+	bot.consumableMinutesThreshold = 600
+
+	shared.robots = []*Bot{bot}
+
+	return bot
+}
+
+func newRobotBot(shared *botShared, name string, robotApi *valetudo.ValetudoClient, storage storage.Storage) *Bot {
+	bot := &Bot{
+		botShared:       shared,
+		name:            name,
+		robotApi:        robotApi,
+		storage:         storage,
+		cleanSelections: map[string]*cleanSelection{},
+		timerDrafts:     map[int64]*timerDraft{},
+		liveStatuses:    map[int64]*liveStatus{},
+	}
+
+	bot.telegramApi = &robotTelegramApi{BotAPI: shared.telegramApi, robot: bot}
+
+	return bot
 }
 
+// AddUserId allows user from configuration to use the bot, these users are always admins
 func (bot *Bot) AddUserId(id int64) {
-	bot.chatIds = append(bot.chatIds, id)
+	bot.usersMutex.Lock()
+	defer bot.usersMutex.Unlock()
+
+	if !slices.Contains(bot.configUserIds, id) {
+		bot.configUserIds = append(bot.configUserIds, id)
+	}
+
+	bot.setUserRoleInMemory(id, roleAdmin)
 }
 
 func (bot *Bot) Start() error {
-	capabilities, err := bot.robotApi.GetRobotCapabilities()
-
+	err := bot.loadStoredUsers()
 	if err != nil {
-		return fmt.Errorf("failed to get robot capabilities: %w", err)
+		return fmt.Errorf("failed to load users: %w", err)
 	}
 
-	bot.capabilities = *capabilities
+	for _, robot := range bot.robots {
+		err = robot.loadCapabilities()
+		if err != nil {
+			return robot.wrapError(err)
+		}
+	}
 
 	err = bot.publishMyCommands()
 
@@ -41,12 +134,19 @@ func (bot *Bot) Start() error {
 		return fmt.Errorf("failed to publish commands: %w", err)
 	}
 
-	go func() {
-		err = bot.listenToStateChanges()
-		if err != nil {
-			log.Println(fmt.Errorf("failed to listen to state changes: %w", err))
-		}
-	}()
+	go bot.runHeldNotificationsDelivery()
+
+	for _, robot := range bot.robots {
+		go robot.runScheduler()
+		go robot.runEventsPolling()
+
+		go func(robot *Bot) {
+			err := robot.listenToStateChanges()
+			if err != nil {
+				log.Println(robot.wrapError(fmt.Errorf("failed to listen to state changes: %w", err)))
+			}
+		}(robot)
+	}
 
 	err = bot.listenToMessages()
 	if err != nil {
@@ -63,10 +163,28 @@ func (bot *Bot) listenToStateChanges() error {
 		return err
 	}
 
+	// changes that happened while the bot wasn't running were either already reported or are stale now,
+	// the stored state only closes cleaning session that ended in the meantime, without reporting it
+	storedState := CurrentState{}
+	found, err := bot.storage.Get(lastStateStorageKey, &storedState)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to load last state: %w", err))
+	}
+
+	if found && storedState.Status != lastState.Status {
+		bot.trackSession(&storedState, lastState)
+	}
+
+	if !found || bot.hasNotifiedStateChanged(&storedState, lastState) {
+		bot.saveLastState(lastState)
+	}
+
+	bot.setLatestState(lastState)
+
 	for {
 		log.Println("Listening for state changes...")
 
-		err = bot.robotApi.ListenToStateAttributesChanges(func(state *[]valetudo.RobotStateAttribute, err error) {
+		err = bot.robotApi.ListenToStateAttributesChanges(func(state *valetudo.RobotStateAttributes, err error) {
 			if err != nil {
 				log.Println(err)
 				return
@@ -76,12 +194,11 @@ func (bot *Bot) listenToStateChanges() error {
 
 			log.Println("Received state, status: ", parsed.Status, " batteryStatus:", parsed.BatteryStatus, " batteryLevel:", parsed.BatteryLevel)
 
-			if lastState.BatteryStatus != parsed.BatteryStatus {
-				bot.handleBatteryStatusChange(lastState, parsed)
-			}
+			bot.setLatestState(parsed)
+			bot.handleStateUpdate(lastState, parsed)
 
-			if lastState.Status != parsed.Status {
-				bot.handleStatusChange(lastState, parsed)
+			if bot.hasNotifiedStateChanged(lastState, parsed) {
+				bot.saveLastState(parsed)
 			}
 
 			lastState = parsed
@@ -93,61 +210,133 @@ func (bot *Bot) listenToStateChanges() error {
 	}
 }
 
-func (bot *Bot) handleStatusChange(previous *CurrentState, new *CurrentState) {
-	for _, user := range bot.chatIds {
-		newStatusLabel := localizeRobotStatus(new.Status)
-		newStatusIcon := robotStatusEmoji(new.Status)
-		statusMessage := newStatusIcon + " " + newStatusLabel
-
-		// Special status transitions that aren't actually a separate statuses
-		switch new.Status {
-		case "returning":
-			if previous.Status == "cleaning" {
-				statusMessage = "✅ Cleaning complete, returning home"
-			}
+// hasNotifiedStateChanged tells if fields that notifications are sent about changed, so battery level alone doesn't rewrite storage
+func (bot *Bot) hasNotifiedStateChanged(previous *CurrentState, new *CurrentState) bool {
+	if previous.Status != new.Status || previous.StatusFlag != new.StatusFlag || previous.BatteryStatus != new.BatteryStatus {
+		return true
+	}
+
+	if !reflect.DeepEqual(previous.Error, new.Error) || len(previous.Consumables) != len(new.Consumables) {
+		return true
+	}
+
+	for i, consumable := range new.Consumables {
+		if bot.isConsumableLow(consumable) != bot.isConsumableLow(previous.Consumables[i]) {
+			return true
 		}
+	}
+
+	return false
+}
+
+func (bot *Bot) handleStateUpdate(previous *CurrentState, new *CurrentState) {
+	var session *cleaningSession
+	if previous.Status != new.Status {
+		session = bot.trackSession(previous, new)
+	}
+
+	if previous.BatteryStatus != new.BatteryStatus {
+		bot.handleBatteryStatusChange(previous, new)
+	}
+
+	if session != nil && session.EndReason == sessionEndCompleted {
+		bot.sendCompletionReport(*session)
+	} else if previous.Status != new.Status {
+		bot.handleStatusChange(previous, new, session)
+	}
 
-		bot.Send(user, statusMessage)
+	// robot can run into another error without leaving the error state
+	if previous.Status == "error" && new.Status == "error" && !reflect.DeepEqual(previous.Error, new.Error) {
+		bot.handleError(new)
 	}
+
+	bot.handleConsumablesChange(previous, new)
 }
 
-func (bot *Bot) handleBatteryStatusChange(previous *CurrentState, new *CurrentState) {
-	for _, user := range bot.chatIds {
-		statusMessage := ""
+// handleStatusChange notifies about new status, session is the one that just ended without being completed, if any
+func (bot *Bot) handleStatusChange(previous *CurrentState, new *CurrentState, session *cleaningSession) {
+	if new.Status == "error" {
+		bot.handleError(new)
+		return
+	}
 
-		switch new.BatteryStatus {
-		case "charging":
-			statusMessage = fmt.Sprintf("🪫 Charging battery from %d %%", new.BatteryLevel)
-		case "charged":
-			statusMessage = "🔋 Battery fully charged"
+	newStatusLabel := localizeRobotStatus(new.Status)
+	newStatusIcon := robotStatusEmoji(new.Status)
+	statusMessage := newStatusIcon + " " + newStatusLabel
+	category := notificationStatus
+
+	// Special status transitions that aren't actually a separate statuses
+	switch new.Status {
+	case "returning":
+		// sessions stopped by the user aren't complete
+		if previous.Status == "cleaning" && session == nil {
+			statusMessage = "✅ Cleaning complete, returning home"
+			category = notificationReports
 		}
+	}
 
-		if statusMessage != "" {
-			bot.Send(user, statusMessage)
+	bot.notify(category, statusMessage, nil)
+}
+
+// handleError notifies about robot error with its details and buttons to deal with it
+func (bot *Bot) handleError(state *CurrentState) {
+	message := robotStatusEmoji(state.Status) + " " + localizeRobotStatus(state.Status)
+
+	if state.Error != nil {
+		if state.Error.Message != "" {
+			message += ": " + state.Error.Message
 		}
+
+		message += "\n⚙️ Subsystem: " + localizeErrorSubsystem(state.Error.Subsystem)
+		message += "\n" + localizeErrorSeverity(state.Error.Severity)
 	}
+
+	actions := tgbotapi.NewInlineKeyboardRow()
+
+	// start of a robot that can't resume would clean everything instead of the interrupted rooms or zones
+	if state.StatusFlag == "resumable" {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("▶️ Resume", "start"))
+	}
+
+	actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("🏠 Home", "home"))
+
+	if bot.HasCapability("LocateCapability") {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("📍 Locate", "locate"))
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		actions,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗺 Show map", "status"),
+		),
+	)
+
+	bot.notify(notificationErrors, message, markup)
 }
 
-func (bot *Bot) isAllowedUserId(id int64) bool {
-	for _, allowedId := range bot.chatIds {
-		if allowedId == id {
-			return true
-		}
+func (bot *Bot) handleBatteryStatusChange(previous *CurrentState, new *CurrentState) {
+	statusMessage := ""
+
+	switch new.BatteryStatus {
+	case "charging":
+		statusMessage = fmt.Sprintf("🪫 Charging battery from %d %%", new.BatteryLevel)
+	case "charged":
+		statusMessage = "🔋 Battery fully charged"
 	}
 
-	return false
+	if statusMessage != "" {
+		bot.notify(notificationBattery, statusMessage, nil)
+	}
 }
 
 func (bot *Bot) listenToMessages() error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := bot.telegramApi.GetUpdatesChan(u)
+	updates := bot.getUpdatesChan(60)
 
 	for update := range updates {
 		if update.CallbackQuery != nil {
-			if !bot.isAllowedUserId(update.CallbackQuery.Message.Chat.ID) {
-				callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "You are not allowed to do that")
+			robot, data, ok := bot.selectCallbackRobot(update.CallbackQuery)
+			if !ok {
+				callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⛔ Unknown robot, send the command again")
 				if _, err := bot.telegramApi.Request(callback); err != nil {
 					log.Println(err)
 				}
@@ -155,119 +344,12 @@ func (bot *Bot) listenToMessages() error {
 				continue
 			}
 
-			data := strings.Split(update.CallbackQuery.Data, " ")
-			switch data[0] {
-			case "pause":
-				err := bot.robotApi.Pause()
-				if err != nil {
-					log.Println(err)
-					bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error pausing robot: "+err.Error())
-				} else {
-					callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⏸ Paused")
-					if _, err := bot.telegramApi.Request(callback); err != nil {
-						log.Println(err)
-					}
-				}
-
-			case "stop":
-				err := bot.robotApi.Stop()
-				if err != nil {
-					log.Println(err)
-					bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error stopping robot: "+err.Error())
-				} else {
-					callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⏹ Stopped")
-					if _, err := bot.telegramApi.Request(callback); err != nil {
-						log.Println(err)
-					}
-				}
+			update.CallbackQuery.Data = data
 
-			case "home":
-				err := bot.robotApi.Home()
-				if err != nil {
-					log.Println(err)
-					bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error sending robot home: "+err.Error())
-				} else {
-					callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "🏠 Going home")
-					if _, err := bot.telegramApi.Request(callback); err != nil {
-						log.Println(err)
-					}
-				}
-
-			case "mode":
-				bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
-					target := args[0]
-					err := bot.robotApi.SetOperationModeControlCapabilityPreset(target)
-					if err != nil {
-						return "", err
-					}
-
-					return "✔️ Mode set to " + localizeOperationMode(target), nil
-				})
-
-			case "fan":
-				bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
-					targetSpeed := args[0]
-					err := bot.robotApi.SetFanSpeedControlCapabilityPreset(targetSpeed)
-					if err != nil {
-						return "", err
-					}
-
-					return "✔️ Fan speed set to " + localizeFanSpeed(targetSpeed), nil
-				})
-
-			case "water":
-				bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
-					target := args[0]
-					err := bot.robotApi.SetWaterUsageControlCapabilityPreset(target)
-					if err != nil {
-						return "", err
-					}
-
-					return "✔️ Water usage set to " + localizeWaterGrade(target), nil
-				})
-
-			case "clean":
-				if len(data) < 2 {
-					bot.handleCleanCommand(update.CallbackQuery.Message.Chat.ID, "")
-					callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "You need to pick what")
-					if _, err := bot.telegramApi.Request(callback); err != nil {
-						log.Println(err)
-					}
-
-					continue
-				}
-
-				err := bot.robotApi.CleanMapSegments([]string{data[1]}, 1)
-
-				if err != nil {
-					bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error cleaning room: "+err.Error())
-					log.Println(err)
-				} else {
-					roomName := data[1]
-					rooms, err := bot.getRooms()
-
-					if err == nil {
-						for _, room := range *rooms {
-							if *room.Metadata.SegmentId == data[1] {
-								roomName = *room.Metadata.Name
-								break
-							}
-						}
-					} else {
-						log.Println(err)
-					}
-
-					bot.telegramApi.Request(
-						tgbotapi.EditMessageTextConfig{
-							BaseEdit: tgbotapi.BaseEdit{
-								ChatID:      update.CallbackQuery.Message.Chat.ID,
-								MessageID:   update.CallbackQuery.Message.MessageID,
-								ReplyMarkup: nil,
-							},
-							Text: "🧹 Cleaning " + roomName,
-						},
-					)
-				}
+			if update.CallbackQuery.Message != nil {
+				bot.setReplyThread(update.CallbackQuery.Message.Chat.ID, update.ThreadId)
+				robot.handleCallbackQuery(update)
+				bot.setReplyThread(update.CallbackQuery.Message.Chat.ID, 0)
 			}
 
 			continue
@@ -277,71 +359,448 @@ func (bot *Bot) listenToMessages() error {
 			continue
 		}
 
-		if !bot.isAllowedUserId(update.Message.From.ID) {
-			bot.Send(update.Message.From.ID, "⚠️ You're not allowed to access this bot. Your ID: "+fmt.Sprintf("%d", update.Message.From.ID))
-
+		if !bot.isCommandForMe(update.Message) {
 			continue
 		}
 
-		if !update.Message.IsCommand() {
-			continue
+		bot.setReplyThread(update.Message.Chat.ID, update.ThreadId)
+		bot.selectMessageRobot(update.Message).handleMessage(update)
+		bot.setReplyThread(update.Message.Chat.ID, 0)
+	}
+
+	return nil
+}
+
+// handleCallbackQuery handles button pressed under message sent by this robot
+func (bot *Bot) handleCallbackQuery(update receivedUpdate) {
+	role := bot.getEffectiveRole(update.CallbackQuery.Message.Chat, update.CallbackQuery.From)
+	if role == "" {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "You are not allowed to do that")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			log.Println(err)
 		}
 
-		switch update.Message.Command() {
-		case "start":
-			bot.Send(update.CallbackQuery.Message.Chat.ID, "👋 I'm ready, /status or /clean")
-		case "status":
-			err := bot.handleStatusCommand(update.Message.Chat.ID, update.Message.CommandArguments())
-			if err != nil {
+		return
+	}
+
+	data := strings.Split(update.CallbackQuery.Data, " ")
+
+	// "Everything" button of keyboards sent by older versions of the bot
+	if update.CallbackQuery.Data == "clean_all" {
+		data = []string{"clean", "all"}
+	}
+
+	if required := requiredRole(callbackRoles, data); !role.allows(required) {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⛔ You need to be "+localizeUserRole(required)+" to do that")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			log.Println(err)
+		}
+
+		return
+	}
+
+	switch data[0] {
+	case "start":
+		err := bot.robotApi.Start()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error starting robot: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "▶️ Started")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error fetching status: "+err.Error())
 			}
-		case "stop":
-			err := bot.robotApi.Stop()
-			if err != nil {
+		}
+
+	case "status":
+		err := bot.handleStatusCommand(update.CallbackQuery.Message.Chat.ID, "")
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error fetching status: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error stopping robot: "+err.Error())
 			}
-		case "home":
-			err := bot.robotApi.Home()
-			if err != nil {
+		}
+
+	case "pause":
+		err := bot.robotApi.Pause()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error pausing robot: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⏸ Paused")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error sending robot home: "+err.Error())
 			}
-		case "pause":
-			err := bot.robotApi.Pause()
-			if err != nil {
+		}
+
+	case "stop":
+		err := bot.stopRobot()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error stopping robot: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "⏹ Stopped")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error pausing robot: "+err.Error())
 			}
-		case "clean":
-			err := bot.handleCleanCommand(update.Message.Chat.ID, update.Message.CommandArguments())
-			if err != nil {
+		}
+
+	case "home":
+		err := bot.sendRobotHome()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error sending robot home: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "🏠 Going home")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error cleaning: "+err.Error())
 			}
-		case "mode":
-			err := bot.handleModeCommand(update.Message.Chat.ID, update.Message.CommandArguments())
-			if err != nil {
+		}
+
+	case "locate":
+		err := bot.handleLocateCommand(update.CallbackQuery.Message.Chat.ID, "")
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error locating robot: "+err.Error())
+		} else {
+			callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "🔊 Locating")
+			if _, err := bot.telegramApi.Request(callback); err != nil {
 				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error setting mode: "+err.Error())
 			}
-		case "fan":
-			err := bot.handleFanCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		}
+
+	case "drive":
+		err := bot.handleDriveCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error controlling robot: "+err.Error())
+		}
+
+	case "mode":
+		bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			target := args[0]
+			err := bot.robotApi.SetOperationModeControlCapabilityPreset(target)
 			if err != nil {
-				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error setting fan speed: "+err.Error())
+				return "", err
 			}
-		case "water":
-			err := bot.handleWaterCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+
+			return "✔️ Mode set to " + localizeOperationMode(target), nil
+		})
+
+	case "fan":
+		bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			targetSpeed := args[0]
+			err := bot.robotApi.SetFanSpeedControlCapabilityPreset(targetSpeed)
 			if err != nil {
-				log.Println(err)
-				bot.Send(update.Message.Chat.ID, "❌ Error setting water grade: "+err.Error())
+				return "", err
 			}
+
+			return "✔️ Fan speed set to " + localizeFanSpeed(targetSpeed), nil
+		})
+
+	case "water":
+		bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			target := args[0]
+			err := bot.robotApi.SetWaterUsageControlCapabilityPreset(target)
+			if err != nil {
+				return "", err
+			}
+
+			return "✔️ Water usage set to " + localizeWaterGrade(target), nil
+		})
+
+	case "zone":
+		bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			name := strings.Join(args, " ")
+			zone, err := bot.findZone(name)
+			if err != nil {
+				return "", err
+			}
+
+			if zone == nil {
+				return "", fmt.Errorf("zone %s not found", name)
+			}
+
+			err = bot.cleanZones([]savedZone{*zone})
+			if err != nil {
+				return "", err
+			}
+
+			return "🧹 Cleaning zone " + zone.Name, nil
+		})
+
+	case "goto":
+		bot.handleOneTimeCallback(update.CallbackQuery, data[1:], func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			point, err := bot.goToPoint(strings.Join(args, " "))
+			if err != nil {
+				return "", err
+			}
+
+			return "🚩 Going to " + point.Name, nil
+		})
+
+	case "consumable":
+		err := bot.handleConsumableCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error resetting consumable: "+err.Error())
+		}
+
+	case "rooms":
+		err := bot.handleRoomsCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error editing rooms: "+err.Error())
+		}
+
+	case "restrictions":
+		err := bot.handleRestrictionsCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error changing restrictions: "+err.Error())
+		}
+
+	case "timers":
+		err := bot.handleTimersCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error changing timers: "+err.Error())
+		}
+
+	case "schedule":
+		err := bot.handleScheduleCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error changing schedule: "+err.Error())
+		}
+
+	case "live":
+		err := bot.handleLiveCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error updating status: "+err.Error())
+		}
+
+	case "event":
+		err := bot.handleEventCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error handling event: "+err.Error())
+		}
+
+	case "robot":
+		err := bot.handleRobotCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error selecting robot: "+err.Error())
+		}
+
+	case "history":
+		err := bot.handleHistoryCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error showing history: "+err.Error())
+		}
+
+	case "notify":
+		err := bot.handleNotifyCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error changing notifications: "+err.Error())
+		}
+
+	case "users":
+		err := bot.handleUsersCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error managing users: "+err.Error())
+		}
+
+	case "clean":
+		err := bot.handleCleanCallback(update.CallbackQuery, data[1:])
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.CallbackQuery.Message.Chat.ID, "❌ Error cleaning room: "+err.Error())
 		}
 	}
 
-	return nil
+}
+
+// handleMessage handles message or command addressed to this robot
+func (bot *Bot) handleMessage(update receivedUpdate) {
+	role := bot.getEffectiveRole(update.Message.Chat, update.Message.From)
+	if role == "" {
+		if update.Message.Command() == "start" && bot.redeemInvite(update.Message) {
+			return
+		}
+
+		if update.Message.Chat.IsPrivate() {
+			bot.Send(update.Message.Chat.ID, "⚠️ You're not allowed to access this bot. Your ID: "+fmt.Sprintf("%d", update.Message.Chat.ID))
+		} else if update.Message.IsCommand() {
+			bot.Send(update.Message.Chat.ID, fmt.Sprintf("⚠️ This chat isn't allowed to access this bot. Chat ID: %d", update.Message.Chat.ID))
+		}
+
+		return
+	}
+
+	if bot.handlePrompt(update.Message, role) {
+		return
+	}
+
+	if !update.Message.IsCommand() {
+		return
+	}
+
+	commandParts := append([]string{update.Message.Command()}, strings.Fields(update.Message.CommandArguments())...)
+	if required := requiredRole(commandRoles, commandParts); !role.allows(required) {
+		bot.Send(update.Message.Chat.ID, "⛔ You need to be "+localizeUserRole(required)+" to do that")
+		return
+	}
+
+	switch update.Message.Command() {
+	case "start":
+		bot.Send(update.Message.Chat.ID, "👋 I'm ready, /status or /clean")
+	case "status":
+		var err error
+		if strings.TrimSpace(update.Message.CommandArguments()) == "all" {
+			err = bot.handleStatusAllCommand(update.Message.Chat.ID)
+		} else {
+			err = bot.handleStatusCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		}
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching status: "+err.Error())
+		}
+	case "stop":
+		err := bot.stopRobot()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error stopping robot: "+err.Error())
+		}
+	case "home":
+		err := bot.sendRobotHome()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error sending robot home: "+err.Error())
+		}
+	case "pause":
+		err := bot.robotApi.Pause()
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error pausing robot: "+err.Error())
+		}
+	case "clean":
+		err := bot.handleCleanCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error cleaning: "+err.Error())
+		}
+	case "zone":
+		err := bot.handleZoneCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error cleaning zone: "+err.Error())
+		}
+	case "locate":
+		err := bot.handleLocateCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error locating robot: "+err.Error())
+		}
+	case "drive":
+		err := bot.handleDriveCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error enabling manual control: "+err.Error())
+		}
+	case "goto":
+		err := bot.handleGoToCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error sending robot to point: "+err.Error())
+		}
+	case "consumables":
+		err := bot.handleConsumablesCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching consumables: "+err.Error())
+		}
+	case "rooms":
+		err := bot.handleRoomsCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching rooms: "+err.Error())
+		}
+	case "restrictions":
+		err := bot.handleRestrictionsCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching restrictions: "+err.Error())
+		}
+	case "timers":
+		err := bot.handleTimersCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching timers: "+err.Error())
+		}
+	case "schedule":
+		err := bot.handleScheduleCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error changing schedule: "+err.Error())
+		}
+	case "robot":
+		err := bot.handleRobotCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error selecting robot: "+err.Error())
+		}
+	case "history":
+		err := bot.handleHistoryCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error fetching history: "+err.Error())
+		}
+	case "notify":
+		err := bot.handleNotifyCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error changing notifications: "+err.Error())
+		}
+	case "topic":
+		err := bot.handleTopicCommand(update.Message.Chat.ID, update.ThreadId, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error changing notification topic: "+err.Error())
+		}
+	case "users":
+		err := bot.handleUsersCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error managing users: "+err.Error())
+		}
+	case "mode":
+		err := bot.handleModeCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error setting mode: "+err.Error())
+		}
+	case "fan":
+		err := bot.handleFanCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error setting fan speed: "+err.Error())
+		}
+	case "water":
+		err := bot.handleWaterCommand(update.Message.Chat.ID, update.Message.CommandArguments())
+		if err != nil {
+			log.Println(err)
+			bot.Send(update.Message.Chat.ID, "❌ Error setting water grade: "+err.Error())
+		}
+	}
 }
 
 func (bot *Bot) HasCapability(capability string) bool {
@@ -0,0 +1,521 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const notificationPreferencesStorageKey = "notification_preferences"
+const heldNotificationsStorageKey = "held_notifications"
+
+// how often held notifications are checked for delivery
+const heldNotificationsInterval = time.Minute
+
+const (
+	notificationStatus      = "status"
+	notificationBattery     = "battery"
+	notificationErrors      = "errors"
+	notificationReports     = "reports"
+	notificationConsumables = "consumables"
+	notificationSchedule    = "schedule"
+	notificationEvents      = "events"
+)
+
+var notificationCategories = []string{
+	notificationStatus,
+	notificationBattery,
+	notificationErrors,
+	notificationReports,
+	notificationConsumables,
+	notificationSchedule,
+	notificationEvents,
+}
+
+const (
+	/** messages are delivered together when quiet hours end */
+	quietModeHold = "hold"
+	/** messages are delivered without sound */
+	quietModeSilent = "silent"
+	/** messages are not delivered at all */
+	quietModeDrop = "drop"
+)
+
+var quietModes = []string{quietModeHold, quietModeSilent, quietModeDrop}
+
+type quietHours struct {
+	/** minutes since midnight in bot timezone, From > To means quiet hours go over midnight */
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Mode string `json:"mode"`
+}
+
+// notificationPreferences of a chat, categories are enabled unless listed in Disabled
+type notificationPreferences struct {
+	Disabled   []string    `json:"disabled"`
+	QuietHours *quietHours `json:"quietHours"`
+}
+
+func (quiet *quietHours) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+
+	if quiet.From <= quiet.To {
+		return minute >= quiet.From && minute < quiet.To
+	}
+
+	return minute >= quiet.From || minute < quiet.To
+}
+
+func formatDayMinute(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// parseQuietHours parses "22:00-07:00"
+func parseQuietHours(value string) (*quietHours, error) {
+	fromValue, toValue, found := strings.Cut(strings.TrimSpace(value), "-")
+	if !found {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM, for example 22:00-07:00")
+	}
+
+	from, err := time.Parse("15:04", strings.TrimSpace(fromValue))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid time, expected HH:MM", fromValue)
+	}
+
+	to, err := time.Parse("15:04", strings.TrimSpace(toValue))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid time, expected HH:MM", toValue)
+	}
+
+	return &quietHours{
+		From: from.Hour()*60 + from.Minute(),
+		To:   to.Hour()*60 + to.Minute(),
+		Mode: quietModeHold,
+	}, nil
+}
+
+func localizeNotificationCategory(category string) string {
+	switch category {
+	case notificationStatus:
+		return "🤖 Status changes"
+	case notificationBattery:
+		return "🔋 Battery"
+	case notificationErrors:
+		return "❌ Errors"
+	case notificationReports:
+		return "✅ Cleaning reports"
+	case notificationConsumables:
+		return "🧰 Consumables"
+	case notificationSchedule:
+		return "🗓 Schedule"
+	case notificationEvents:
+		return "📬 Robot events"
+	}
+
+	return category
+}
+
+func localizeQuietMode(mode string) string {
+	switch mode {
+	case quietModeHold:
+		return "held until morning"
+	case quietModeSilent:
+		return "sent silently"
+	case quietModeDrop:
+		return "dropped"
+	}
+
+	return mode
+}
+
+func (bot *Bot) getAllNotificationPreferences() (map[int64]notificationPreferences, error) {
+	preferences := map[int64]notificationPreferences{}
+
+	_, err := bot.sharedStorage.Get(notificationPreferencesStorageKey, &preferences)
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (bot *Bot) getNotificationPreferences(chatId int64) (notificationPreferences, error) {
+	preferences, err := bot.getAllNotificationPreferences()
+	if err != nil {
+		return notificationPreferences{}, err
+	}
+
+	return preferences[chatId], nil
+}
+
+func (bot *Bot) updateNotificationPreferences(chatId int64, update func(preferences *notificationPreferences)) error {
+	bot.notificationsMutex.Lock()
+	defer bot.notificationsMutex.Unlock()
+
+	all, err := bot.getAllNotificationPreferences()
+	if err != nil {
+		return err
+	}
+
+	preferences := all[chatId]
+	update(&preferences)
+	all[chatId] = preferences
+
+	return bot.sharedStorage.Set(notificationPreferencesStorageKey, all)
+}
+
+// notify sends notification of specified category to every user who wants it, respecting their quiet hours
+func (bot *Bot) notify(category string, text string, markup any) {
+	text = bot.labelNotification(text)
+
+	for _, user := range bot.getUserIds() {
+		msg := tgbotapi.NewMessage(user, text)
+		msg.ReplyMarkup = markup
+
+		if err := bot.notifyUser(category, msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// notifyPhoto sends notification with image to every user who wants it, held notifications only keep the caption
+func (bot *Bot) notifyPhoto(category string, image []byte, caption string) {
+	caption = bot.labelNotification(caption)
+
+	for _, user := range bot.getUserIds() {
+		deliver, silent, err := bot.getNotificationDelivery(user, category, caption)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if !deliver {
+			continue
+		}
+
+		msg := tgbotapi.NewPhoto(user, tgbotapi.FileBytes{
+			Name:  "map.png",
+			Bytes: image,
+		})
+		msg.Caption = caption
+		msg.DisableNotification = silent
+
+		if err := bot.sendNotificationPhoto(msg); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (bot *Bot) notifyUser(category string, msg tgbotapi.MessageConfig) error {
+	deliver, silent, err := bot.getNotificationDelivery(msg.ChatID, category, msg.Text)
+	if err != nil || !deliver {
+		return err
+	}
+
+	msg.DisableNotification = silent
+
+	_, err = bot.sendNotification(msg)
+
+	return err
+}
+
+// getNotificationDelivery decides whether notification should be sent to the chat right now and whether silently,
+// notifications that should wait for the end of quiet hours are held here
+func (bot *Bot) getNotificationDelivery(chatId int64, category string, text string) (bool, bool, error) {
+	preferences, err := bot.getNotificationPreferences(chatId)
+	if err != nil {
+		return false, false, err
+	}
+
+	if slices.Contains(preferences.Disabled, category) {
+		return false, false, nil
+	}
+
+	quiet := preferences.QuietHours
+	if quiet != nil && quiet.contains(time.Now().In(bot.timezone)) {
+		switch quiet.Mode {
+		case quietModeDrop:
+			return false, false, nil
+		case quietModeSilent:
+			return true, true, nil
+		default:
+			return false, false, bot.holdNotification(chatId, text)
+		}
+	}
+
+	return true, false, nil
+}
+
+func (bot *Bot) holdNotification(chatId int64, text string) error {
+	bot.notificationsMutex.Lock()
+	defer bot.notificationsMutex.Unlock()
+
+	held := map[int64][]string{}
+	_, err := bot.sharedStorage.Get(heldNotificationsStorageKey, &held)
+	if err != nil {
+		return err
+	}
+
+	held[chatId] = append(held[chatId], text)
+
+	return bot.sharedStorage.Set(heldNotificationsStorageKey, held)
+}
+
+// runHeldNotificationsDelivery sends held notifications to users whose quiet hours ended, until the bot exits
+func (bot *Bot) runHeldNotificationsDelivery() {
+	for {
+		err := bot.deliverHeldNotifications(time.Now().In(bot.timezone))
+		if err != nil {
+			log.Println(fmt.Errorf("failed to deliver held notifications: %w", err))
+		}
+
+		time.Sleep(heldNotificationsInterval)
+	}
+}
+
+func (bot *Bot) deliverHeldNotifications(now time.Time) error {
+	bot.notificationsMutex.Lock()
+
+	held := map[int64][]string{}
+	_, err := bot.sharedStorage.Get(heldNotificationsStorageKey, &held)
+	if err != nil {
+		bot.notificationsMutex.Unlock()
+		return err
+	}
+
+	preferences, err := bot.getAllNotificationPreferences()
+	if err != nil {
+		bot.notificationsMutex.Unlock()
+		return err
+	}
+
+	due := map[int64][]string{}
+	for chatId, messages := range held {
+		quiet := preferences[chatId].QuietHours
+		if quiet == nil || !quiet.contains(now) {
+			due[chatId] = messages
+			delete(held, chatId)
+		}
+	}
+
+	if len(due) > 0 {
+		err = bot.sharedStorage.Set(heldNotificationsStorageKey, held)
+	}
+
+	bot.notificationsMutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for chatId, messages := range due {
+		text := "🌙 While you were in quiet hours:\n\n" + strings.Join(messages, "\n")
+		if _, err := bot.sendNotification(tgbotapi.NewMessage(chatId, text)); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return nil
+}
+
+func (bot *Bot) buildNotifyMessage(chatId int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	preferences, err := bot.getNotificationPreferences(chatId)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := "🔔 Notifications\nTap a category to turn it on or off."
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, category := range notificationCategories {
+		label := "✅ " + localizeNotificationCategory(category)
+		if slices.Contains(preferences.Disabled, category) {
+			label = "⬜ " + localizeNotificationCategory(category)
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "notify toggle "+category),
+		))
+	}
+
+	if quiet := preferences.QuietHours; quiet != nil {
+		text += fmt.Sprintf(
+			"\n\n🌙 Quiet hours %s-%s, messages are %s",
+			formatDayMinute(quiet.From),
+			formatDayMinute(quiet.To),
+			localizeQuietMode(quiet.Mode),
+		)
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Change mode", "notify mode"),
+			tgbotapi.NewInlineKeyboardButtonData("🔕 Disable quiet hours", "notify quiet off"),
+		))
+	} else {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Set quiet hours", "notify quiet"),
+		))
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) setQuietHours(chatId int64, value string) error {
+	if strings.TrimSpace(value) == "off" {
+		err := bot.updateNotificationPreferences(chatId, func(preferences *notificationPreferences) {
+			preferences.QuietHours = nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(chatId, "🔔 Quiet hours disabled")
+	}
+
+	quiet, err := parseQuietHours(value)
+	if err != nil {
+		return err
+	}
+
+	err = bot.updateNotificationPreferences(chatId, func(preferences *notificationPreferences) {
+		if preferences.QuietHours != nil {
+			quiet.Mode = preferences.QuietHours.Mode
+		}
+
+		preferences.QuietHours = quiet
+	})
+	if err != nil {
+		return err
+	}
+
+	return bot.Send(chatId, fmt.Sprintf("🌙 Quiet hours set to %s-%s", formatDayMinute(quiet.From), formatDayMinute(quiet.To)))
+}
+
+func (bot *Bot) handleNotifyCommand(requesterId int64, args string) error {
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch subcommand {
+	case "quiet":
+		if rest == "" {
+			bot.Send(requesterId, "Usage: /notify quiet 22:00-07:00 or /notify quiet off")
+			return nil
+		}
+
+		return bot.setQuietHours(requesterId, rest)
+
+	case "mode":
+		if !slices.Contains(quietModes, rest) {
+			bot.Send(requesterId, "Usage: /notify mode hold|silent|drop")
+			return nil
+		}
+
+		preferences, err := bot.getNotificationPreferences(requesterId)
+		if err != nil {
+			return err
+		}
+
+		if preferences.QuietHours == nil {
+			return fmt.Errorf("set quiet hours first using /notify quiet 22:00-07:00")
+		}
+
+		err = bot.updateNotificationPreferences(requesterId, func(preferences *notificationPreferences) {
+			preferences.QuietHours.Mode = rest
+		})
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(requesterId, "🌙 Messages during quiet hours will be "+localizeQuietMode(rest))
+	}
+
+	text, keyboard, err := bot.buildNotifyMessage(requesterId)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	msg.ReplyMarkup = keyboard
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) handleNotifyCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	chatId := query.Message.Chat.ID
+
+	switch args[0] {
+	case "toggle":
+		if len(args) < 2 || !slices.Contains(notificationCategories, args[1]) {
+			return nil
+		}
+
+		err := bot.updateNotificationPreferences(chatId, func(preferences *notificationPreferences) {
+			if slices.Contains(preferences.Disabled, args[1]) {
+				preferences.Disabled = slices.DeleteFunc(preferences.Disabled, func(category string) bool {
+					return category == args[1]
+				})
+			} else {
+				preferences.Disabled = append(preferences.Disabled, args[1])
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+	case "mode":
+		err := bot.updateNotificationPreferences(chatId, func(preferences *notificationPreferences) {
+			if preferences.QuietHours == nil {
+				return
+			}
+
+			index := slices.Index(quietModes, preferences.QuietHours.Mode)
+			preferences.QuietHours.Mode = quietModes[(index+1)%len(quietModes)]
+		})
+		if err != nil {
+			return err
+		}
+
+	case "quiet":
+		if len(args) > 1 && args[1] == "off" {
+			err := bot.updateNotificationPreferences(chatId, func(preferences *notificationPreferences) {
+				preferences.QuietHours = nil
+			})
+			if err != nil {
+				return err
+			}
+
+			break
+		}
+
+		return bot.prompt(query, "🌙 Send quiet hours, for example 22:00-07:00", func(chatId int64, text string) error {
+			return bot.setQuietHours(chatId, text)
+		})
+
+	default:
+		return nil
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "")
+	if _, err := bot.telegramApi.Request(callback); err != nil {
+		return err
+	}
+
+	text, keyboard, err := bot.buildNotifyMessage(chatId)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageTextAndMarkup(chatId, query.Message.MessageID, text, keyboard))
+
+	return err
+}
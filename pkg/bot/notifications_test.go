@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		value string
+		from  int
+		to    int
+	}{
+		{value: "22:00-07:00", from: 22 * 60, to: 7 * 60},
+		{value: "13:30-15:45", from: 13*60 + 30, to: 15*60 + 45},
+		{value: " 23:15 - 6:05 ", from: 23*60 + 15, to: 6*60 + 5},
+		{value: "00:00-00:00", from: 0, to: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			quiet, err := parseQuietHours(test.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if quiet.From != test.from || quiet.To != test.to {
+				t.Errorf("expected %d-%d, got %d-%d", test.from, test.to, quiet.From, quiet.To)
+			}
+
+			if quiet.Mode != quietModeHold {
+				t.Errorf("expected mode %s, got %s", quietModeHold, quiet.Mode)
+			}
+		})
+	}
+}
+
+func TestParseQuietHoursErrors(t *testing.T) {
+	for _, value := range []string{"", "22:00", "22:00-", "10pm-7am", "24:00-07:00"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseQuietHours(value)
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	tests := []struct {
+		hours    string
+		at       string
+		expected bool
+	}{
+		// same day
+		{hours: "13:00-15:00", at: "12:59", expected: false},
+		{hours: "13:00-15:00", at: "13:00", expected: true},
+		{hours: "13:00-15:00", at: "14:30", expected: true},
+		{hours: "13:00-15:00", at: "15:00", expected: false},
+		// over midnight
+		{hours: "22:00-07:00", at: "21:59", expected: false},
+		{hours: "22:00-07:00", at: "22:00", expected: true},
+		{hours: "22:00-07:00", at: "23:59", expected: true},
+		{hours: "22:00-07:00", at: "00:00", expected: true},
+		{hours: "22:00-07:00", at: "06:59", expected: true},
+		{hours: "22:00-07:00", at: "07:00", expected: false},
+		{hours: "22:00-07:00", at: "12:00", expected: false},
+		// empty range
+		{hours: "08:00-08:00", at: "08:00", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hours+" "+test.at, func(t *testing.T) {
+			quiet, err := parseQuietHours(test.hours)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			at, err := time.Parse("15:04", test.at)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if quiet.contains(at) != test.expected {
+				t.Errorf("expected %v", test.expected)
+			}
+		})
+	}
+}
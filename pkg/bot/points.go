@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo_map_renderer"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const pointsStorageKey = "points"
+
+type savedPoint struct {
+	Name string `json:"name"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+func (bot *Bot) getPoints() ([]savedPoint, error) {
+	points := []savedPoint{}
+
+	_, err := bot.storage.Get(pointsStorageKey, &points)
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+func (bot *Bot) findPoint(name string) (*savedPoint, error) {
+	points, err := bot.getPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, point := range points {
+		if strings.EqualFold(point.Name, name) {
+			return &point, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (bot *Bot) getPointMarkers() ([]valetudo_map_renderer.MapMarker, error) {
+	points, err := bot.getPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	markers := []valetudo_map_renderer.MapMarker{}
+	for _, point := range points {
+		markers = append(markers, valetudo_map_renderer.MapMarker{
+			X:     point.X,
+			Y:     point.Y,
+			Label: point.Name,
+		})
+	}
+
+	return markers, nil
+}
+
+func (bot *Bot) handleGoToCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("GoToLocationCapability") {
+		return fmt.Errorf("robot doesn't support going to location")
+	}
+
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+
+	switch subcommand {
+	case "":
+		return bot.sendGoToKeyboard(requesterId)
+	case "add":
+		return bot.handleGoToAdd(requesterId, strings.TrimSpace(rest))
+	case "remove", "delete":
+		return bot.handleGoToRemove(requesterId, strings.TrimSpace(rest))
+	}
+
+	point, err := bot.goToPoint(args)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, "🚩 Going to "+point.Name)
+
+	return nil
+}
+
+func (bot *Bot) handleGoToAdd(requesterId int64, name string) error {
+	if name == "" {
+		bot.Send(requesterId, "Usage: /goto add <name>\nSaves current robot position under specified name.")
+		return nil
+	}
+
+	if len(name) > maxSavedNameLength {
+		bot.Send(requesterId, fmt.Sprintf("❌ Point name has to be at most %d bytes long", maxSavedNameLength))
+		return nil
+	}
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	robot := state.Map.FindEntity("robot_position")
+	if robot == nil || robot.Points == nil || len(*robot.Points) < 2 {
+		return fmt.Errorf("robot position is unknown")
+	}
+
+	point := savedPoint{
+		Name: name,
+		X:    (*robot.Points)[0],
+		Y:    (*robot.Points)[1],
+	}
+
+	points, err := bot.getPoints()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range points {
+		if strings.EqualFold(points[i].Name, point.Name) {
+			points[i] = point
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		points = append(points, point)
+	}
+
+	err = bot.storage.Set(pointsStorageKey, points)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, fmt.Sprintf("✅ Point %s saved at [%d, %d]", point.Name, point.X, point.Y))
+
+	return nil
+}
+
+func (bot *Bot) handleGoToRemove(requesterId int64, name string) error {
+	points, err := bot.getPoints()
+	if err != nil {
+		return err
+	}
+
+	result := []savedPoint{}
+	for _, point := range points {
+		if !strings.EqualFold(point.Name, name) {
+			result = append(result, point)
+		}
+	}
+
+	if len(result) == len(points) {
+		bot.Send(requesterId, "❌ Point "+name+" not found")
+		return nil
+	}
+
+	err = bot.storage.Set(pointsStorageKey, result)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, "🗑 Point "+name+" removed")
+
+	return nil
+}
+
+func (bot *Bot) goToPoint(name string) (*savedPoint, error) {
+	point, err := bot.findPoint(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if point == nil {
+		return nil, fmt.Errorf("point %s not found", name)
+	}
+
+	err = bot.robotApi.GoToLocation(point.X, point.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	return point, nil
+}
+
+func (bot *Bot) sendGoToKeyboard(requesterId int64) error {
+	points, err := bot.getPoints()
+	if err != nil {
+		return err
+	}
+
+	if len(points) == 0 {
+		bot.Send(requesterId, "🚩 No points saved yet. Move the robot where you want and use /goto add <name>")
+		return nil
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, point := range points {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚩 "+point.Name, "goto "+point.Name),
+		))
+	}
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	mapImage := bot.renderMap(&state.Map)
+	msg := tgbotapi.NewPhoto(requesterId, tgbotapi.FileBytes{
+		Name:  "map.png",
+		Bytes: mapImage,
+	})
+
+	msg.Caption = "🚩 Where should the robot go?"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
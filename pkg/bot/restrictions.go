@@ -0,0 +1,364 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const restrictionSetsStorageKey = "restriction_sets"
+
+type savedRestrictionSet struct {
+	/** buttons reference sets by id, so they keep working when other sets are added or removed */
+	Id           string                               `json:"id"`
+	Name         string                               `json:"name"`
+	Restrictions valetudo.CombinedVirtualRestrictions `json:"restrictions"`
+}
+
+// newRestrictionSetId returns id that isn't reused even after the set is removed, short enough for callback data
+func newRestrictionSetId() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func (bot *Bot) getRestrictionSets() ([]savedRestrictionSet, error) {
+	sets := []savedRestrictionSet{}
+
+	_, err := bot.storage.Get(restrictionSetsStorageKey, &sets)
+	if err != nil {
+		return nil, err
+	}
+
+	// sets saved before they had ids
+	missingIds := false
+	for i := range sets {
+		if sets[i].Id == "" {
+			sets[i].Id = newRestrictionSetId() + strconv.Itoa(i)
+			missingIds = true
+		}
+	}
+
+	if missingIds {
+		err = bot.storage.Set(restrictionSetsStorageKey, sets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sets, nil
+}
+
+// isRestrictionSetActive checks whether all walls and zones from the set are currently applied
+func isRestrictionSetActive(current valetudo.CombinedVirtualRestrictions, set valetudo.CombinedVirtualRestrictions) bool {
+	if len(set.VirtualWalls) == 0 && len(set.RestrictedZones) == 0 {
+		return false
+	}
+
+	for _, wall := range set.VirtualWalls {
+		if !slices.Contains(current.VirtualWalls, wall) {
+			return false
+		}
+	}
+
+	for _, zone := range set.RestrictedZones {
+		if !slices.Contains(current.RestrictedZones, zone) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func addRestrictions(current valetudo.CombinedVirtualRestrictions, set valetudo.CombinedVirtualRestrictions) valetudo.CombinedVirtualRestrictions {
+	result := valetudo.CombinedVirtualRestrictions{
+		VirtualWalls:    slices.Clone(current.VirtualWalls),
+		RestrictedZones: slices.Clone(current.RestrictedZones),
+	}
+
+	for _, wall := range set.VirtualWalls {
+		if !slices.Contains(result.VirtualWalls, wall) {
+			result.VirtualWalls = append(result.VirtualWalls, wall)
+		}
+	}
+
+	for _, zone := range set.RestrictedZones {
+		if !slices.Contains(result.RestrictedZones, zone) {
+			result.RestrictedZones = append(result.RestrictedZones, zone)
+		}
+	}
+
+	return result
+}
+
+func removeRestrictions(current valetudo.CombinedVirtualRestrictions, set valetudo.CombinedVirtualRestrictions) valetudo.CombinedVirtualRestrictions {
+	result := valetudo.CombinedVirtualRestrictions{
+		VirtualWalls:    []valetudo.VirtualWall{},
+		RestrictedZones: []valetudo.RestrictedZone{},
+	}
+
+	for _, wall := range current.VirtualWalls {
+		if !slices.Contains(set.VirtualWalls, wall) {
+			result.VirtualWalls = append(result.VirtualWalls, wall)
+		}
+	}
+
+	for _, zone := range current.RestrictedZones {
+		if !slices.Contains(set.RestrictedZones, zone) {
+			result.RestrictedZones = append(result.RestrictedZones, zone)
+		}
+	}
+
+	return result
+}
+
+func describeRestrictions(restrictions valetudo.CombinedVirtualRestrictions) string {
+	noGo := 0
+	noMop := 0
+
+	for _, zone := range restrictions.RestrictedZones {
+		if zone.Type == "mop" {
+			noMop++
+		} else {
+			noGo++
+		}
+	}
+
+	return fmt.Sprintf(
+		"🧱 Virtual walls: %d\n⛔ No-go areas: %d\n💧 No-mop areas: %d",
+		len(restrictions.VirtualWalls),
+		noGo,
+		noMop,
+	)
+}
+
+func (bot *Bot) buildRestrictionsMessage(current valetudo.CombinedVirtualRestrictions) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	sets, err := bot.getRestrictionSets()
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := "🚧 Virtual restrictions\n" + describeRestrictions(current)
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	// sets are referenced by id, names wouldn't fit into callback data
+	for _, set := range sets {
+		label := "⬜ " + set.Name
+		if isRestrictionSetActive(current, set.Restrictions) {
+			label = "✅ " + set.Name
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "restrictions set "+set.Id),
+		))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("💾 Save current as set", "restrictions save"),
+	))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) handleRestrictionsCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("CombinedVirtualRestrictionsCapability") {
+		return fmt.Errorf("robot doesn't support virtual restrictions")
+	}
+
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch subcommand {
+	case "save":
+		return bot.saveCurrentRestrictions(requesterId, rest)
+	case "remove", "delete":
+		return bot.removeRestrictionSet(requesterId, rest)
+	}
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	text, keyboard, err := bot.buildRestrictionsMessage(state.Map.GetVirtualRestrictions())
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewPhoto(requesterId, tgbotapi.FileBytes{
+		Name:  "map.png",
+		Bytes: bot.renderMap(&state.Map),
+	})
+
+	msg.Caption = text
+	msg.ReplyMarkup = keyboard
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) saveCurrentRestrictions(requesterId int64, name string) error {
+	if name == "" {
+		bot.Send(requesterId, "Usage: /restrictions save <name>\nSaves currently applied virtual walls and no-go/no-mop areas as a set you can toggle later.")
+		return nil
+	}
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	set := savedRestrictionSet{
+		Name:         name,
+		Restrictions: state.Map.GetVirtualRestrictions(),
+	}
+
+	sets, err := bot.getRestrictionSets()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range sets {
+		if strings.EqualFold(sets[i].Name, name) {
+			set.Id = sets[i].Id
+			sets[i] = set
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		set.Id = newRestrictionSetId()
+		sets = append(sets, set)
+	}
+
+	err = bot.storage.Set(restrictionSetsStorageKey, sets)
+	if err != nil {
+		return err
+	}
+
+	return bot.Send(requesterId, "💾 Restriction set "+name+" saved\n"+describeRestrictions(set.Restrictions))
+}
+
+func (bot *Bot) removeRestrictionSet(requesterId int64, name string) error {
+	sets, err := bot.getRestrictionSets()
+	if err != nil {
+		return err
+	}
+
+	result := []savedRestrictionSet{}
+	for _, set := range sets {
+		if !strings.EqualFold(set.Name, name) {
+			result = append(result, set)
+		}
+	}
+
+	if len(result) == len(sets) {
+		bot.Send(requesterId, "❌ Restriction set "+name+" not found")
+		return nil
+	}
+
+	err = bot.storage.Set(restrictionSetsStorageKey, result)
+	if err != nil {
+		return err
+	}
+
+	return bot.Send(requesterId, "🗑 Restriction set "+name+" removed")
+}
+
+func (bot *Bot) handleRestrictionsCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch args[0] {
+	case "save":
+		return bot.prompt(query, "💾 Send name for the restriction set", func(chatId int64, text string) error {
+			return bot.saveCurrentRestrictions(chatId, text)
+		})
+
+	case "toggle":
+		// buttons referencing sets by index, which changes when sets are added or removed
+		return fmt.Errorf("this message is outdated, send /restrictions again")
+
+	case "set":
+		if len(args) < 2 {
+			return nil
+		}
+
+		sets, err := bot.getRestrictionSets()
+		if err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(sets, func(set savedRestrictionSet) bool {
+			return set.Id == args[1]
+		})
+
+		if index == -1 {
+			return fmt.Errorf("restriction set not found, it was probably removed")
+		}
+
+		state, err := bot.robotApi.GetRobotState()
+		if err != nil {
+			return err
+		}
+
+		set := sets[index]
+		current := state.Map.GetVirtualRestrictions()
+		updated := addRestrictions(current, set.Restrictions)
+		response := "✅ " + set.Name + " enabled"
+
+		if isRestrictionSetActive(current, set.Restrictions) {
+			updated = removeRestrictions(current, set.Restrictions)
+			response = "⬜ " + set.Name + " disabled"
+		}
+
+		properties, err := bot.robotApi.GetCombinedVirtualRestrictionsCapabilityProperties()
+		if err != nil {
+			return err
+		}
+
+		// robots without mop don't support no-mop areas for example
+		supportedZones := []valetudo.RestrictedZone{}
+		for _, zone := range updated.RestrictedZones {
+			if slices.Contains(properties.SupportedRestrictedZoneTypes, zone.Type) {
+				supportedZones = append(supportedZones, zone)
+			}
+		}
+		updated.RestrictedZones = supportedZones
+
+		err = bot.robotApi.SetVirtualRestrictions(updated)
+		if err != nil {
+			return err
+		}
+
+		callback := tgbotapi.NewCallback(query.ID, response)
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			return err
+		}
+
+		text, keyboard, err := bot.buildRestrictionsMessage(updated)
+		if err != nil {
+			return err
+		}
+
+		_, err = bot.telegramApi.Request(tgbotapi.EditMessageCaptionConfig{
+			BaseEdit: tgbotapi.BaseEdit{
+				ChatID:      query.Message.Chat.ID,
+				MessageID:   query.Message.MessageID,
+				ReplyMarkup: &keyboard,
+			},
+			Caption: text,
+		})
+
+		return err
+	}
+
+	return nil
+}
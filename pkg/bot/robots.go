@@ -0,0 +1,437 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/storage"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const selectedRobotsStorageKey = "selected_robots"
+
+// buttons of robots that aren't alone carry "@index " before their callback data
+const callbackRobotPrefix = "@"
+
+// MaxRobots keeps the robot index a single digit, see maxCallbackRobotTagLength
+const MaxRobots = 10
+
+// telegram rejects messages with buttons carrying more data than this
+const maxCallbackDataLength = 64
+
+// length of "@index " added to callback data when there are more robots
+const maxCallbackRobotTagLength = len(callbackRobotPrefix) + 2
+
+// botShared is state common to all robots served by one telegram bot
+type botShared struct {
+	telegramApi *tgbotapi.BotAPI
+	/** users, notification settings and other data not related to a single robot */
+	sharedStorage storage.Storage
+
+	/** all robots in configuration order, the first one is the default */
+	robots []*Bot
+
+	/** all users allowed to use the bot, users from configuration are always admins */
+	chatIds       []int64
+	configUserIds []int64
+	userRoles     map[int64]userRole
+	usersMutex    sync.RWMutex
+	invitesMutex  sync.Mutex
+
+	/** guards notification preferences and held notifications, see notifications.go */
+	notificationsMutex sync.Mutex
+
+	/** forum topic of the update being handled in each chat, replies are sent there */
+	replyThreads      map[int64]int
+	replyThreadsMutex sync.Mutex
+
+	/** questions waiting for text reply, by chat and user, the handler belongs to the robot that asked */
+	pendingPrompts map[promptKey]pendingPrompt
+}
+
+// robotTelegramApi adds robot index to buttons of sent messages, so they control the robot that sent them
+type robotTelegramApi struct {
+	*tgbotapi.BotAPI
+	robot *Bot
+}
+
+// Send sends message into the forum topic of the update being handled in the chat, see setReplyThread
+func (api *robotTelegramApi) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return api.SendToThread(c, api.robot.getReplyThread(getChattableChatId(c)))
+}
+
+// SendToThread sends message into forum topic, 0 sends it outside of topics or to the general topic
+func (api *robotTelegramApi) SendToThread(c tgbotapi.Chattable, threadId int) (tgbotapi.Message, error) {
+	c = api.robot.tagChattable(c)
+
+	if threadId == 0 {
+		return api.BotAPI.Send(c)
+	}
+
+	endpoint, params, files, err := threadRequest(c, threadId)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var response *tgbotapi.APIResponse
+	if len(files) > 0 {
+		response, err = api.BotAPI.UploadFiles(endpoint, params, files)
+	} else {
+		response, err = api.BotAPI.MakeRequest(endpoint, params)
+	}
+
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	sent := tgbotapi.Message{}
+	err = json.Unmarshal(response.Result, &sent)
+
+	return sent, err
+}
+
+func (api *robotTelegramApi) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return api.BotAPI.Request(api.robot.tagChattable(c))
+}
+
+func (api *robotTelegramApi) MakeRequest(endpoint string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	return api.BotAPI.MakeRequest(endpoint, api.robot.tagParams(params))
+}
+
+func (api *robotTelegramApi) UploadFiles(endpoint string, params tgbotapi.Params, files []tgbotapi.RequestFile) (*tgbotapi.APIResponse, error) {
+	return api.BotAPI.UploadFiles(endpoint, api.robot.tagParams(params), files)
+}
+
+func (bot *Bot) isTaggingCallbacks() bool {
+	return bot.name != "" && len(bot.robots) > 1
+}
+
+// callbackRobotTag identifies the robot in callback data, index is used because names could be too long
+func (bot *Bot) callbackRobotTag() string {
+	return callbackRobotPrefix + strconv.Itoa(slices.Index(bot.robots, bot)) + " "
+}
+
+// tagKeyboard returns copy of the keyboard with robot index in callback data of every button
+func (bot *Bot) tagKeyboard(keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, len(keyboard.InlineKeyboard))
+
+	for i, row := range keyboard.InlineKeyboard {
+		rows[i] = make([]tgbotapi.InlineKeyboardButton, len(row))
+
+		for j, button := range row {
+			if button.CallbackData != nil {
+				data := bot.callbackRobotTag() + *button.CallbackData
+				button.CallbackData = &data
+			}
+
+			rows[i][j] = button
+		}
+	}
+
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (bot *Bot) tagReplyMarkup(markup interface{}) interface{} {
+	switch keyboard := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return bot.tagKeyboard(keyboard)
+	case *tgbotapi.InlineKeyboardMarkup:
+		if keyboard != nil {
+			tagged := bot.tagKeyboard(*keyboard)
+			return &tagged
+		}
+	}
+
+	return markup
+}
+
+func (bot *Bot) tagBaseEdit(edit tgbotapi.BaseEdit) tgbotapi.BaseEdit {
+	if edit.ReplyMarkup != nil {
+		tagged := bot.tagKeyboard(*edit.ReplyMarkup)
+		edit.ReplyMarkup = &tagged
+	}
+
+	return edit
+}
+
+// tagChattable adds robot index to buttons of messages this bot sends or edits
+func (bot *Bot) tagChattable(c tgbotapi.Chattable) tgbotapi.Chattable {
+	if !bot.isTaggingCallbacks() {
+		return c
+	}
+
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		config.ReplyMarkup = bot.tagReplyMarkup(config.ReplyMarkup)
+		return config
+	case tgbotapi.PhotoConfig:
+		config.ReplyMarkup = bot.tagReplyMarkup(config.ReplyMarkup)
+		return config
+	case tgbotapi.EditMessageTextConfig:
+		config.BaseEdit = bot.tagBaseEdit(config.BaseEdit)
+		return config
+	case tgbotapi.EditMessageCaptionConfig:
+		config.BaseEdit = bot.tagBaseEdit(config.BaseEdit)
+		return config
+	case tgbotapi.EditMessageMediaConfig:
+		config.BaseEdit = bot.tagBaseEdit(config.BaseEdit)
+		return config
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		config.BaseEdit = bot.tagBaseEdit(config.BaseEdit)
+		return config
+	}
+
+	return c
+}
+
+// tagParams is tagChattable for requests made directly, the keyboard is already encoded in them
+func (bot *Bot) tagParams(params tgbotapi.Params) tgbotapi.Params {
+	if !bot.isTaggingCallbacks() || params["reply_markup"] == "" {
+		return params
+	}
+
+	keyboard := tgbotapi.InlineKeyboardMarkup{}
+	if err := json.Unmarshal([]byte(params["reply_markup"]), &keyboard); err != nil || len(keyboard.InlineKeyboard) == 0 {
+		return params
+	}
+
+	tagged := tgbotapi.Params{}
+	for key, value := range params {
+		tagged[key] = value
+	}
+
+	if err := tagged.AddInterface("reply_markup", bot.tagKeyboard(keyboard)); err != nil {
+		log.Println(err)
+		return params
+	}
+
+	return tagged
+}
+
+// AddRobot serves another robot by the same telegram bot, its data is stored separately under its name
+func (bot *Bot) AddRobot(name string, robotApi *valetudo.ValetudoClient) *Bot {
+	robot := newRobotBot(bot.botShared, name, robotApi, storage.WithPrefix(bot.sharedStorage, "robots."+name+"."))
+	robot.scheduleMinBattery = bot.scheduleMinBattery
+	robot.timezone = bot.timezone
+	robot.consumablePercentThreshold = bot.consumablePercentThreshold
+	robot.consumableMinutesThreshold = bot.consumableMinutesThreshold
+
+	bot.robots = append(bot.robots, robot)
+
+	return robot
+}
+
+// SetName sets name the robot is selected by, the first robot keeps its data where it was before naming it
+func (bot *Bot) SetName(name string) {
+	bot.name = name
+}
+
+func (bot *Bot) robotByName(name string) *Bot {
+	for _, robot := range bot.robots {
+		if robot.name != "" && strings.EqualFold(robot.name, name) {
+			return robot
+		}
+	}
+
+	return nil
+}
+
+// wrapError adds robot name to errors of robots that aren't alone
+func (bot *Bot) wrapError(err error) error {
+	if bot.name == "" || len(bot.robots) < 2 {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", bot.name, err)
+}
+
+// labelNotification prefixes notification with robot name when there are more robots
+func (bot *Bot) labelNotification(text string) string {
+	if bot.name == "" || len(bot.robots) < 2 {
+		return text
+	}
+
+	return "[" + bot.name + "] " + text
+}
+
+func (bot *Bot) getSelectedRobots() (map[int64]string, error) {
+	selected := map[int64]string{}
+
+	_, err := bot.sharedStorage.Get(selectedRobotsStorageKey, &selected)
+	if err != nil {
+		return nil, err
+	}
+
+	return selected, nil
+}
+
+// getChatRobot returns robot picked by /robot in the chat, the first robot if there's none
+func (bot *Bot) getChatRobot(chatId int64) *Bot {
+	selected, err := bot.getSelectedRobots()
+	if err != nil {
+		log.Println(err)
+	}
+
+	if robot := bot.robotByName(selected[chatId]); robot != nil {
+		return robot
+	}
+
+	return bot.robots[0]
+}
+
+// selectMessageRobot picks robot from /command@robot, otherwise the robot selected in the chat
+func (bot *Bot) selectMessageRobot(message *tgbotapi.Message) *Bot {
+	if _, target, found := strings.Cut(message.CommandWithAt(), "@"); found {
+		if robot := bot.robotByName(target); robot != nil {
+			return robot
+		}
+	}
+
+	return bot.getChatRobot(message.Chat.ID)
+}
+
+// selectCallbackRobot picks robot tagged in callback data and returns the data meant for it,
+// buttons without a known robot are refused rather than sent to a robot the user didn't mean
+func (bot *Bot) selectCallbackRobot(query *tgbotapi.CallbackQuery) (*Bot, string, bool) {
+	if len(bot.robots) < 2 {
+		return bot.robots[0], query.Data, true
+	}
+
+	if !strings.HasPrefix(query.Data, callbackRobotPrefix) {
+		return nil, "", false
+	}
+
+	tag, data, _ := strings.Cut(strings.TrimPrefix(query.Data, callbackRobotPrefix), " ")
+
+	robot := bot.robotByIndex(tag)
+	if robot == nil {
+		return nil, "", false
+	}
+
+	return robot, data, true
+}
+
+// robotByIndex returns robot by its position in configuration, nil when there's no such robot
+func (bot *Bot) robotByIndex(value string) *Bot {
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= len(bot.robots) {
+		return nil
+	}
+
+	return bot.robots[index]
+}
+
+func (bot *Bot) buildRobotKeyboard(chatId int64) tgbotapi.InlineKeyboardMarkup {
+	current := bot.getChatRobot(chatId)
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+
+	for i, robot := range bot.robots {
+		label := "🤖 " + robot.name
+		if robot == current {
+			label = "✅ " + robot.name
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "robot select "+strconv.Itoa(i)),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (bot *Bot) selectChatRobot(chatId int64, name string) error {
+	robot := bot.robotByName(name)
+	if robot == nil {
+		return fmt.Errorf("unknown robot %s", name)
+	}
+
+	selected, err := bot.getSelectedRobots()
+	if err != nil {
+		return err
+	}
+
+	selected[chatId] = robot.name
+
+	return bot.sharedStorage.Set(selectedRobotsStorageKey, selected)
+}
+
+// handleRobotCommand picks robot controlled by commands without @robot in the chat
+func (bot *Bot) handleRobotCommand(chatId int64, args string) error {
+	if len(bot.robots) < 2 {
+		return bot.Send(chatId, "🤖 There's only one robot")
+	}
+
+	name := strings.TrimSpace(args)
+	if name != "" {
+		err := bot.selectChatRobot(chatId, name)
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(chatId, "🤖 Commands in this chat now control "+bot.getChatRobot(chatId).name)
+	}
+
+	msg := tgbotapi.NewMessage(chatId, "🤖 Which robot should commands in this chat control? Use /command@robot to control the other one just once")
+	msg.ReplyMarkup = bot.buildRobotKeyboard(chatId)
+
+	_, err := bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) handleRobotCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) < 2 || args[0] != "select" {
+		return nil
+	}
+
+	chatId := query.Message.Chat.ID
+
+	robot := bot.robotByIndex(args[1])
+	if robot == nil {
+		return fmt.Errorf("unknown robot")
+	}
+
+	err := bot.selectChatRobot(chatId, robot.name)
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, query.Message.MessageID, bot.buildRobotKeyboard(chatId)))
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewCallback(query.ID, "🤖 "+bot.getChatRobot(chatId).name+" selected"))
+
+	return err
+}
+
+// handleStatusAllCommand sends status with map of every robot
+func (bot *Bot) handleStatusAllCommand(chatId int64) error {
+	for _, robot := range bot.robots {
+		err := robot.handleStatusCommand(chatId, "")
+		if err != nil {
+			log.Println(err)
+			robot.Send(chatId, "❌ Error fetching status of "+robot.name+": "+err.Error())
+		}
+	}
+
+	return nil
+}
+
+// anyRobotHasCapability is used for command list shared by all robots
+func (bot *Bot) anyRobotHasCapability(capability string) bool {
+	for _, robot := range bot.robots {
+		if robot.HasCapability(capability) {
+			return true
+		}
+	}
+
+	return false
+}
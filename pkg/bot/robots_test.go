@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/storage"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// valetudo uses UUIDs for timers and events
+const testUuid = "0e3b8a5e-8f7a-4c6b-9d3e-2f1a0b9c8d7e"
+
+func newTestRobots(count int) []*Bot {
+	shared := &botShared{sharedStorage: storage.NewMemoryStorage()}
+
+	for i := 0; i < count; i++ {
+		shared.robots = append(shared.robots, &Bot{botShared: shared, name: strings.Repeat("r", 40)})
+	}
+
+	return shared.robots
+}
+
+func TestTaggedCallbackDataLength(t *testing.T) {
+	robots := newTestRobots(MaxRobots)
+	robot := robots[len(robots)-1]
+
+	longestName := strings.Repeat("n", maxSavedNameLength)
+
+	keyboards := map[string]tgbotapi.InlineKeyboardMarkup{
+		"event":       buildEventKeyboard(valetudo.ValetudoEvent{Class: valetudo.PendingMapChangeEventClass, Id: testUuid}),
+		"event reset": buildEventKeyboard(valetudo.ValetudoEvent{Class: valetudo.ConsumableDepletedEventClass, Id: testUuid}),
+		"robot":       robot.buildRobotKeyboard(0),
+		"other": tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("", "timers confirm_delete "+testUuid),
+			tgbotapi.NewInlineKeyboardButtonData("", "timers toggle "+testUuid),
+			tgbotapi.NewInlineKeyboardButtonData("", "zone "+longestName),
+			tgbotapi.NewInlineKeyboardButtonData("", "goto "+longestName),
+			tgbotapi.NewInlineKeyboardButtonData("", "restrictions set "+newRestrictionSetId()+"99"),
+		)),
+	}
+
+	for name, keyboard := range keyboards {
+		t.Run(name, func(t *testing.T) {
+			tagged := robot.tagKeyboard(keyboard)
+
+			for _, row := range tagged.InlineKeyboard {
+				for _, button := range row {
+					if len(*button.CallbackData) > maxCallbackDataLength {
+						t.Errorf("%q is %d bytes long", *button.CallbackData, len(*button.CallbackData))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSelectCallbackRobot(t *testing.T) {
+	robots := newTestRobots(3)
+
+	tests := []struct {
+		data     string
+		robot    *Bot
+		expected string
+		ok       bool
+	}{
+		{data: robots[2].callbackRobotTag() + "start", robot: robots[2], expected: "start", ok: true},
+		{data: robots[0].callbackRobotTag() + "zone Dining table", robot: robots[0], expected: "zone Dining table", ok: true},
+		{data: "start", ok: false},
+		{data: "@3 start", ok: false},
+		{data: "@name start", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.data, func(t *testing.T) {
+			robot, data, ok := robots[0].selectCallbackRobot(&tgbotapi.CallbackQuery{Data: test.data})
+
+			if ok != test.ok || robot != test.robot || data != test.expected {
+				t.Errorf("expected %v %q %v, got %v %q %v", test.robot, test.expected, test.ok, robot, data, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"strings"
+)
+
+type userRole string
+
+const (
+	/** can check status and receive notifications */
+	roleViewer userRole = "viewer"
+	/** can also start, stop and steer the robot */
+	roleOperator userRole = "operator"
+	/** can also change settings, manage users and remove saved data */
+	roleAdmin userRole = "admin"
+)
+
+// role given to users added at runtime when nothing else is specified
+const defaultUserRole = roleOperator
+
+var roleLevels = map[userRole]int{
+	roleViewer:   1,
+	roleOperator: 2,
+	roleAdmin:    3,
+}
+
+var roleOrder = []userRole{roleViewer, roleOperator, roleAdmin}
+
+// minimal role for each command, "command subcommand" rules take precedence over "command" ones
+var commandRoles = map[string]userRole{
+	"start":       roleViewer,
+	"status":      roleViewer,
+	"consumables": roleViewer,
+	"notify":      roleViewer,
+	"history":     roleViewer,
+	"robot":       roleViewer,
+
+	"clean":  roleOperator,
+	"pause":  roleOperator,
+	"stop":   roleOperator,
+	"home":   roleOperator,
+	"locate": roleOperator,
+	"drive":  roleOperator,
+	"mode":   roleOperator,
+	"fan":    roleOperator,
+	"water":  roleOperator,
+	"zone":   roleOperator,
+	"goto":   roleOperator,
+
+	"zone add":     roleAdmin,
+	"zone remove":  roleAdmin,
+	"zone delete":  roleAdmin,
+	"goto add":     roleAdmin,
+	"goto remove":  roleAdmin,
+	"goto delete":  roleAdmin,
+	"rooms":        roleAdmin,
+	"restrictions": roleAdmin,
+	"timers":       roleAdmin,
+	"schedule":     roleAdmin,
+	"users":        roleAdmin,
+	"topic":        roleAdmin,
+}
+
+// minimal role for each callback, keyed by the callback prefix the same way as commands
+var callbackRoles = map[string]userRole{
+	"robot":   roleViewer,
+	"notify":  roleViewer,
+	"history": roleViewer,
+	"live":    roleViewer,
+	"status":  roleViewer,
+
+	"start":  roleOperator,
+	"pause":  roleOperator,
+	"stop":   roleOperator,
+	"home":   roleOperator,
+	"locate": roleOperator,
+	"drive":  roleOperator,
+	"mode":   roleOperator,
+	"fan":    roleOperator,
+	"water":  roleOperator,
+	"zone":   roleOperator,
+	"goto":   roleOperator,
+	"clean":  roleOperator,
+	"event":  roleOperator,
+
+	"event yes":    roleAdmin,
+	"event no":     roleAdmin,
+	"event reset":  roleAdmin,
+	"consumable":   roleAdmin,
+	"rooms":        roleAdmin,
+	"restrictions": roleAdmin,
+	"timers":       roleAdmin,
+	"schedule":     roleAdmin,
+	"users":        roleAdmin,
+}
+
+func parseUserRole(value string) (userRole, bool) {
+	role := userRole(strings.ToLower(strings.TrimSpace(value)))
+	_, ok := roleLevels[role]
+
+	return role, ok
+}
+
+func (role userRole) allows(required userRole) bool {
+	return roleLevels[role] >= roleLevels[required]
+}
+
+// next cycles viewer -> operator -> admin -> viewer
+func (role userRole) next() userRole {
+	for i, candidate := range roleOrder {
+		if candidate == role {
+			return roleOrder[(i+1)%len(roleOrder)]
+		}
+	}
+
+	return defaultUserRole
+}
+
+func localizeUserRole(role userRole) string {
+	switch role {
+	case roleViewer:
+		return "👀 Viewer"
+	case roleOperator:
+		return "🕹 Operator"
+	case roleAdmin:
+		return "👑 Admin"
+	}
+
+	return string(role)
+}
+
+// requiredRole finds the rule for command and its arguments, unknown actions require admin
+func requiredRole(rules map[string]userRole, parts []string) userRole {
+	if len(parts) == 0 {
+		return roleAdmin
+	}
+
+	if len(parts) > 1 {
+		if role, ok := rules[strings.ToLower(parts[0]+" "+parts[1])]; ok {
+			return role
+		}
+	}
+
+	if role, ok := rules[strings.ToLower(parts[0])]; ok {
+		return role
+	}
+
+	return roleAdmin
+}
+
+// getUserRole returns empty role for users who aren't allowed to use the bot
+func (bot *Bot) getUserRole(id int64) userRole {
+	bot.usersMutex.RLock()
+	defer bot.usersMutex.RUnlock()
+
+	return bot.userRoles[id]
+}
@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo_map_renderer"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// roomMarkers returns label with segment id and name placed in the middle of every room
+func roomMarkers(robotMap *valetudo.RobotStateMap) []valetudo_map_renderer.MapMarker {
+	markers := []valetudo_map_renderer.MapMarker{}
+
+	for _, layer := range robotMap.Layers {
+		if layer.Type != "segment" || layer.Metadata.SegmentId == nil {
+			continue
+		}
+
+		label := *layer.Metadata.SegmentId
+		if layer.Metadata.Name != nil {
+			label += ": " + *layer.Metadata.Name
+		}
+
+		markers = append(markers, valetudo_map_renderer.MapMarker{
+			X:     layer.Dimensions.X.Mid * robotMap.PixelSize,
+			Y:     layer.Dimensions.Y.Mid * robotMap.PixelSize,
+			Label: label,
+		})
+	}
+
+	return markers
+}
+
+func (bot *Bot) buildRoomsMenuKeyboard() tgbotapi.InlineKeyboardMarkup {
+	row := []tgbotapi.InlineKeyboardButton{}
+
+	if bot.HasCapability("MapSegmentRenameCapability") {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("✏️ Rename", "rooms rename"))
+	}
+
+	if bot.HasCapability("MapSegmentEditCapability") {
+		row = append(
+			row,
+			tgbotapi.NewInlineKeyboardButtonData("🔗 Join", "rooms join"),
+			tgbotapi.NewInlineKeyboardButtonData("✂️ Split", "rooms split"),
+		)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// buildRoomsPickerKeyboard lists rooms as buttons with callback prefix followed by segment id
+func (bot *Bot) buildRoomsPickerKeyboard(prefix string, exclude string) (tgbotapi.InlineKeyboardMarkup, error) {
+	rooms, err := bot.getSegments()
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, room := range rooms {
+		if *room.Metadata.SegmentId == exclude {
+			continue
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(segmentLabel(room), prefix+" "+*room.Metadata.SegmentId),
+		))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("↩️ Back", "rooms menu"),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+// getSegments returns all segments including the ones without name, sorted by id
+func (bot *Bot) getSegments() ([]valetudo.RobotStateMapLayer, error) {
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []valetudo.RobotStateMapLayer{}
+	for _, layer := range state.Map.Layers {
+		if layer.Type == "segment" && layer.Metadata.SegmentId != nil {
+			result = append(result, layer)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, errA := strconv.Atoi(*result[i].Metadata.SegmentId)
+		b, errB := strconv.Atoi(*result[j].Metadata.SegmentId)
+		if errA != nil || errB != nil {
+			return *result[i].Metadata.SegmentId < *result[j].Metadata.SegmentId
+		}
+
+		return a < b
+	})
+
+	return result, nil
+}
+
+func segmentLabel(layer valetudo.RobotStateMapLayer) string {
+	if layer.Metadata.Name != nil {
+		return *layer.Metadata.SegmentId + ": " + *layer.Metadata.Name
+	}
+
+	return *layer.Metadata.SegmentId
+}
+
+func (bot *Bot) getSegmentLabel(segmentId string) string {
+	segments, err := bot.getSegments()
+	if err != nil {
+		return segmentId
+	}
+
+	for _, segment := range segments {
+		if *segment.Metadata.SegmentId == segmentId {
+			return segmentLabel(segment)
+		}
+	}
+
+	return segmentId
+}
+
+func (bot *Bot) handleRoomsCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("MapSegmentRenameCapability") && !bot.HasCapability("MapSegmentEditCapability") {
+		return fmt.Errorf("robot doesn't support editing rooms")
+	}
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	options := bot.getMapRenderOptions()
+	options.Markers = append(options.Markers, roomMarkers(&state.Map)...)
+
+	mapImage := valetudo_map_renderer.RenderMapWithOptions(&state.Map, options)
+	msg := tgbotapi.NewPhoto(requesterId, tgbotapi.FileBytes{
+		Name:  "map.png",
+		Bytes: mapImage,
+	})
+
+	msg.Caption = "🗺 What do you want to do with the rooms?"
+	msg.ReplyMarkup = bot.buildRoomsMenuKeyboard()
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) handleRoomsCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	chatId := query.Message.Chat.ID
+	messageId := query.Message.MessageID
+
+	switch args[0] {
+	case "menu":
+		_, err := bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, messageId, bot.buildRoomsMenuKeyboard()))
+		return err
+
+	case "rename":
+		if len(args) < 2 {
+			keyboard, err := bot.buildRoomsPickerKeyboard("rooms rename", "")
+			if err != nil {
+				return err
+			}
+
+			_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, messageId, keyboard))
+			return err
+		}
+
+		segmentId := args[1]
+		label := bot.getSegmentLabel(segmentId)
+
+		return bot.prompt(query, "✏️ Send new name for "+label, func(chatId int64, text string) error {
+			if text == "" {
+				return fmt.Errorf("name can't be empty")
+			}
+
+			// /clean and /schedule separate rooms by commas
+			if strings.Contains(text, ",") {
+				return fmt.Errorf("name can't contain commas")
+			}
+
+			err := bot.robotApi.RenameMapSegment(segmentId, text)
+			if err != nil {
+				return err
+			}
+
+			return bot.Send(chatId, "✅ Room "+label+" renamed to "+text)
+		})
+
+	case "join":
+		if len(args) < 2 {
+			keyboard, err := bot.buildRoomsPickerKeyboard("rooms join", "")
+			if err != nil {
+				return err
+			}
+
+			_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, messageId, keyboard))
+			return err
+		}
+
+		if len(args) < 3 {
+			keyboard, err := bot.buildRoomsPickerKeyboard("rooms join "+args[1], args[1])
+			if err != nil {
+				return err
+			}
+
+			_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, messageId, keyboard))
+			if err != nil {
+				return err
+			}
+
+			callback := tgbotapi.NewCallback(query.ID, "Now pick the room to join with")
+			_, err = bot.telegramApi.Request(callback)
+
+			return err
+		}
+
+		labelA := bot.getSegmentLabel(args[1])
+		labelB := bot.getSegmentLabel(args[2])
+
+		bot.handleOneTimeCallback(query, args, func(query *tgbotapi.CallbackQuery, args []string) (string, error) {
+			err := bot.robotApi.JoinMapSegments(args[1], args[2])
+			if err != nil {
+				return "", err
+			}
+
+			return "🔗 Joined " + labelA + " and " + labelB, nil
+		})
+
+		return nil
+
+	case "split":
+		if len(args) < 2 {
+			keyboard, err := bot.buildRoomsPickerKeyboard("rooms split", "")
+			if err != nil {
+				return err
+			}
+
+			_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, messageId, keyboard))
+			return err
+		}
+
+		segmentId := args[1]
+		label := bot.getSegmentLabel(segmentId)
+
+		return bot.prompt(query, "✂️ Send the line to split "+label+" along as \"x1 y1 x2 y2\" (in cm, same coordinates as Valetudo map)", func(chatId int64, text string) error {
+			fields := strings.Fields(text)
+			if len(fields) != 4 {
+				return fmt.Errorf("expected 4 numbers, got %d", len(fields))
+			}
+
+			coordinates := []int{}
+			for _, field := range fields {
+				value, err := strconv.Atoi(field)
+				if err != nil {
+					return fmt.Errorf("%s is not a number", field)
+				}
+
+				coordinates = append(coordinates, value)
+			}
+
+			err := bot.robotApi.SplitMapSegment(
+				segmentId,
+				valetudo.MapPoint{X: coordinates[0], Y: coordinates[1]},
+				valetudo.MapPoint{X: coordinates[2], Y: coordinates[3]},
+			)
+			if err != nil {
+				return err
+			}
+
+			return bot.Send(chatId, "✂️ Room "+label+" split")
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,630 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const scheduleStorageKey = "schedule"
+
+// how often the scheduler checks for jobs to run
+const schedulerTickInterval = 20 * time.Second
+
+type scheduledJob struct {
+	Id int `json:"id"`
+	// time.Weekday values, 0 is Sunday
+	Days   []int `json:"days"`
+	Hour   int   `json:"hour"`
+	Minute int   `json:"minute"`
+	// room names, empty means everything
+	Rooms      []string `json:"rooms"`
+	Iterations int      `json:"iterations"`
+	FanSpeed   string   `json:"fanSpeed,omitempty"`
+	WaterGrade string   `json:"waterGrade,omitempty"`
+	Mode       string   `json:"mode,omitempty"`
+	SkipNext   bool     `json:"skipNext"`
+	// prevents running the job twice in the same minute
+	LastRun time.Time `json:"lastRun"`
+}
+
+type scheduleState struct {
+	Jobs   []scheduledJob `json:"jobs"`
+	NextId int            `json:"nextId"`
+	// all jobs are skipped while paused, useful for vacations
+	Paused bool `json:"paused"`
+}
+
+var scheduleDayNames = map[string]int{
+	"sun": 0,
+	"mon": 1,
+	"tue": 2,
+	"wed": 3,
+	"thu": 4,
+	"fri": 5,
+	"sat": 6,
+}
+
+// SetTimezone applies to all robots added so far and the ones added later
+func (bot *Bot) SetTimezone(location *time.Location) {
+	for _, robot := range bot.robots {
+		robot.timezone = location
+	}
+}
+
+func (bot *Bot) SetScheduleMinBattery(level int) {
+	for _, robot := range bot.robots {
+		robot.scheduleMinBattery = level
+	}
+}
+
+// loadSchedule expects scheduleMutex to be held
+func (bot *Bot) loadSchedule() (*scheduleState, error) {
+	state := scheduleState{Jobs: []scheduledJob{}, NextId: 1}
+
+	_, err := bot.storage.Get(scheduleStorageKey, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// saveSchedule expects scheduleMutex to be held
+func (bot *Bot) saveSchedule(state *scheduleState) error {
+	return bot.storage.Set(scheduleStorageKey, state)
+}
+
+// updateSchedule loads schedule, lets the callback modify it and saves it back
+func (bot *Bot) updateSchedule(update func(state *scheduleState) error) error {
+	bot.scheduleMutex.Lock()
+	defer bot.scheduleMutex.Unlock()
+
+	state, err := bot.loadSchedule()
+	if err != nil {
+		return err
+	}
+
+	err = update(state)
+	if err != nil {
+		return err
+	}
+
+	return bot.saveSchedule(state)
+}
+
+// parseScheduleDays accepts "daily", "weekdays", "weekends", "mon-fri", "mon,wed,fri" and combinations
+func parseScheduleDays(value string) ([]int, error) {
+	switch strings.ToLower(value) {
+	case "daily", "everyday":
+		return []int{0, 1, 2, 3, 4, 5, 6}, nil
+	case "weekdays":
+		return []int{1, 2, 3, 4, 5}, nil
+	case "weekends":
+		return []int{0, 6}, nil
+	}
+
+	days := []int{}
+
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		start, ok := scheduleDayNames[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %s", from)
+		}
+
+		if !isRange {
+			if !slices.Contains(days, start) {
+				days = append(days, start)
+			}
+
+			continue
+		}
+
+		end, ok := scheduleDayNames[to]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %s", to)
+		}
+
+		// ranges can wrap around the week, like fri-mon
+		for day := start; ; day = (day + 1) % 7 {
+			if !slices.Contains(days, day) {
+				days = append(days, day)
+			}
+
+			if day == end {
+				break
+			}
+		}
+	}
+
+	slices.Sort(days)
+
+	return days, nil
+}
+
+// parseScheduledJob parses "<days> <HH:MM> clean [rooms|all] [2x] [fan=max] [water=low] [mode=vacuum]"
+func parseScheduledJob(args string) (*scheduledJob, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 3 || fields[2] != "clean" {
+		return nil, fmt.Errorf("expected <days> <HH:MM> clean [rooms] [2x] [fan=...] [water=...] [mode=...]")
+	}
+
+	days, err := parseScheduleDays(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid time, expected HH:MM", fields[1])
+	}
+
+	job := scheduledJob{
+		Days:       days,
+		Hour:       at.Hour(),
+		Minute:     at.Minute(),
+		Rooms:      []string{},
+		Iterations: 1,
+	}
+
+	roomsPart := []string{}
+
+	for _, field := range fields[3:] {
+		key, value, isOption := strings.Cut(field, "=")
+
+		if isOption {
+			switch strings.ToLower(key) {
+			case "fan":
+				job.FanSpeed = value
+			case "water":
+				job.WaterGrade = value
+			case "mode":
+				job.Mode = value
+			default:
+				return nil, fmt.Errorf("unknown option %s", key)
+			}
+
+			continue
+		}
+
+		if rest, iterations := parseIterationsArgument(field); rest == "" {
+			job.Iterations = iterations
+			continue
+		}
+
+		roomsPart = append(roomsPart, field)
+	}
+
+	rooms := strings.Join(roomsPart, " ")
+	if rooms != "" && rooms != "all" {
+		for _, room := range strings.Split(rooms, ",") {
+			job.Rooms = append(job.Rooms, strings.TrimSpace(room))
+		}
+	}
+
+	return &job, nil
+}
+
+func describeScheduledJob(job scheduledJob) string {
+	target := "everything"
+	if len(job.Rooms) > 0 {
+		target = strings.Join(job.Rooms, ", ")
+	}
+
+	description := fmt.Sprintf("#%d %s %02d:%02d clean %s%s", job.Id, formatTimerDays(job.Days), job.Hour, job.Minute, target, formatIterations(job.Iterations))
+
+	if job.FanSpeed != "" {
+		description += ", fan " + localizeFanSpeed(job.FanSpeed)
+	}
+
+	if job.WaterGrade != "" {
+		description += ", water " + localizeWaterGrade(job.WaterGrade)
+	}
+
+	if job.Mode != "" {
+		description += ", mode " + localizeOperationMode(job.Mode)
+	}
+
+	if job.SkipNext {
+		description += " ⏭ skipping next run"
+	}
+
+	return description
+}
+
+func (bot *Bot) buildScheduleMessage() (string, tgbotapi.InlineKeyboardMarkup, error) {
+	bot.scheduleMutex.Lock()
+	state, err := bot.loadSchedule()
+	bot.scheduleMutex.Unlock()
+
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := fmt.Sprintf("🗓 Schedule (%s):", bot.timezone.String())
+	if state.Paused {
+		text = "⏸ Schedule is paused, no jobs will run\n" + text
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for _, job := range state.Jobs {
+		text += "\n" + describeScheduledJob(job)
+
+		skipLabel := fmt.Sprintf("⏭ Skip next #%d", job.Id)
+		if job.SkipNext {
+			skipLabel = fmt.Sprintf("↩️ Don't skip #%d", job.Id)
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(skipLabel, fmt.Sprintf("schedule skip %d", job.Id)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Delete #%d", job.Id), fmt.Sprintf("schedule delete %d", job.Id)),
+		))
+	}
+
+	if len(state.Jobs) == 0 {
+		text += "\nNo jobs yet, add one using /schedule add mon-fri 10:00 clean Kitchen,Hallway fan=max"
+	}
+
+	if state.Paused {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Resume all", "schedule resume"),
+		))
+	} else {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏸ Pause all", "schedule pause"),
+		))
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) sendScheduleMessage(requesterId int64) error {
+	text, keyboard, err := bot.buildScheduleMessage()
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	msg.ReplyMarkup = keyboard
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) handleScheduleCommand(requesterId int64, args string) error {
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch subcommand {
+	case "", "list":
+		return bot.sendScheduleMessage(requesterId)
+
+	case "add":
+		job, err := parseScheduledJob(rest)
+		if err != nil {
+			bot.Send(requesterId, "❌ "+err.Error()+"\nExample: /schedule add mon-fri 10:00 clean Kitchen,Hallway 2x fan=max")
+			return nil
+		}
+
+		// more passes of everything are done by cleaning all segments
+		if len(job.Rooms) == 0 && job.Iterations > 1 && !bot.HasCapability("MapSegmentationCapability") {
+			bot.Send(requesterId, "❌ This robot can clean everything only once, pick rooms to clean them more times")
+			return nil
+		}
+
+		err = bot.updateSchedule(func(state *scheduleState) error {
+			job.Id = state.NextId
+			state.NextId++
+			state.Jobs = append(state.Jobs, *job)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(requesterId, "✅ Scheduled "+describeScheduledJob(*job))
+
+	case "remove", "delete", "skip":
+		id, err := strconv.Atoi(strings.TrimPrefix(rest, "#"))
+		if err != nil {
+			bot.Send(requesterId, "Usage: /schedule "+subcommand+" <job number>")
+			return nil
+		}
+
+		if subcommand == "skip" {
+			err = bot.toggleScheduledJobSkip(id)
+		} else {
+			err = bot.removeScheduledJob(id)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return bot.sendScheduleMessage(requesterId)
+
+	case "pause", "resume":
+		err := bot.setSchedulePaused(subcommand == "pause")
+		if err != nil {
+			return err
+		}
+
+		return bot.sendScheduleMessage(requesterId)
+	}
+
+	bot.Send(requesterId, "Usage: /schedule [add|remove|skip|pause|resume]")
+
+	return nil
+}
+
+func (bot *Bot) toggleScheduledJobSkip(id int) error {
+	return bot.updateSchedule(func(state *scheduleState) error {
+		for i := range state.Jobs {
+			if state.Jobs[i].Id == id {
+				state.Jobs[i].SkipNext = !state.Jobs[i].SkipNext
+				return nil
+			}
+		}
+
+		return fmt.Errorf("job #%d not found", id)
+	})
+}
+
+func (bot *Bot) removeScheduledJob(id int) error {
+	return bot.updateSchedule(func(state *scheduleState) error {
+		index := slices.IndexFunc(state.Jobs, func(job scheduledJob) bool {
+			return job.Id == id
+		})
+
+		if index == -1 {
+			return fmt.Errorf("job #%d not found", id)
+		}
+
+		state.Jobs = slices.Delete(state.Jobs, index, index+1)
+
+		return nil
+	})
+}
+
+func (bot *Bot) setSchedulePaused(paused bool) error {
+	return bot.updateSchedule(func(state *scheduleState) error {
+		state.Paused = paused
+		return nil
+	})
+}
+
+func (bot *Bot) handleScheduleCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var err error
+
+	switch args[0] {
+	case "skip", "delete":
+		if len(args) < 2 {
+			return nil
+		}
+
+		id, parseErr := strconv.Atoi(args[1])
+		if parseErr != nil {
+			return parseErr
+		}
+
+		if args[0] == "skip" {
+			err = bot.toggleScheduledJobSkip(id)
+		} else {
+			err = bot.removeScheduledJob(id)
+		}
+
+	case "pause", "resume":
+		err = bot.setSchedulePaused(args[0] == "pause")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	text, keyboard, err := bot.buildScheduleMessage()
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, keyboard))
+
+	return err
+}
+
+// runScheduler checks for due jobs until the bot exits
+func (bot *Bot) runScheduler() {
+	for {
+		bot.runDueJobs(time.Now().In(bot.timezone))
+		time.Sleep(schedulerTickInterval)
+	}
+}
+
+// takeDueJobs marks jobs due at the time as run, schedule is saved only when there are some
+// so the storage isn't rewritten on every tick
+func (bot *Bot) takeDueJobs(now time.Time) ([]scheduledJob, bool, error) {
+	bot.scheduleMutex.Lock()
+	defer bot.scheduleMutex.Unlock()
+
+	state, err := bot.loadSchedule()
+	if err != nil {
+		return nil, false, err
+	}
+
+	due := []scheduledJob{}
+
+	for i := range state.Jobs {
+		job := &state.Jobs[i]
+
+		if !slices.Contains(job.Days, int(now.Weekday())) || job.Hour != now.Hour() || job.Minute != now.Minute() {
+			continue
+		}
+
+		// already handled during this minute
+		if now.Sub(job.LastRun) < time.Minute {
+			continue
+		}
+
+		job.LastRun = now
+		due = append(due, *job)
+
+		// paused schedule doesn't use up the skip, the job neither runs nor is skipped because of it
+		if !state.Paused {
+			job.SkipNext = false
+		}
+	}
+
+	if len(due) == 0 {
+		return due, state.Paused, nil
+	}
+
+	return due, state.Paused, bot.saveSchedule(state)
+}
+
+func (bot *Bot) runDueJobs(now time.Time) {
+	due, paused, err := bot.takeDueJobs(now)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to check schedule: %w", err))
+		return
+	}
+
+	for _, job := range due {
+		if paused {
+			log.Println("Skipping scheduled job", job.Id, "because schedule is paused")
+			continue
+		}
+
+		if job.SkipNext {
+			bot.notify(notificationSchedule, "⏭ Skipped scheduled "+describeScheduledJob(job)+" as requested", nil)
+			continue
+		}
+
+		err := bot.runScheduledJob(job)
+		if err != nil {
+			log.Println(fmt.Errorf("scheduled job #%d failed: %w", job.Id, err))
+			bot.notify(notificationErrors, fmt.Sprintf("❌ Scheduled job #%d failed: %s", job.Id, err.Error()), nil)
+		}
+	}
+}
+
+func (bot *Bot) runScheduledJob(job scheduledJob) error {
+	state, err := bot.getParsedState()
+	if err != nil {
+		return err
+	}
+
+	if state.Status != "docked" && state.Status != "idle" {
+		bot.notify(notificationSchedule, fmt.Sprintf("⏭ Skipped scheduled job #%d, robot is busy (%s)", job.Id, localizeRobotStatus(state.Status)), nil)
+		return nil
+	}
+
+	if state.BatteryLevel < bot.scheduleMinBattery {
+		bot.notify(notificationSchedule, fmt.Sprintf("⏭ Skipped scheduled job #%d, battery is low (%d %%)", job.Id, state.BatteryLevel), nil)
+		return nil
+	}
+
+	if job.Mode != "" {
+		err := bot.robotApi.SetOperationModeControlCapabilityPreset(job.Mode)
+		if err != nil {
+			return fmt.Errorf("failed to set mode: %w", err)
+		}
+	}
+
+	if job.FanSpeed != "" {
+		err := bot.robotApi.SetFanSpeedControlCapabilityPreset(job.FanSpeed)
+		if err != nil {
+			return fmt.Errorf("failed to set fan speed: %w", err)
+		}
+	}
+
+	if job.WaterGrade != "" {
+		err := bot.robotApi.SetWaterUsageControlCapabilityPreset(job.WaterGrade)
+		if err != nil {
+			return fmt.Errorf("failed to set water grade: %w", err)
+		}
+	}
+
+	if len(job.Rooms) == 0 && job.Iterations > 1 {
+		err = bot.cleanAllSegments(job.Iterations)
+	} else if len(job.Rooms) == 0 {
+		err = bot.cleanEverything()
+	} else {
+		err = bot.cleanRoomsByName(job.Rooms, job.Iterations)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bot.notify(notificationSchedule, "🗓 Started scheduled "+describeScheduledJob(job), nil)
+
+	return nil
+}
+
+// cleanAllSegments cleans every segment of the map, unlike cleanEverything it can do more passes
+func (bot *Bot) cleanAllSegments(iterations int) error {
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return err
+	}
+
+	segmentIds := []string{}
+
+	for _, layer := range state.Map.Layers {
+		if layer.Type == valetudo.MapLayerSegment && layer.Metadata.SegmentId != nil {
+			segmentIds = append(segmentIds, *layer.Metadata.SegmentId)
+		}
+	}
+
+	if len(segmentIds) == 0 {
+		return fmt.Errorf("map has no rooms to clean")
+	}
+
+	err = bot.cleanSegments(segmentIds, iterations)
+	if err != nil {
+		return err
+	}
+
+	bot.setSessionTarget("Everything")
+
+	return nil
+}
+
+// cleanRoomsByName resolves room names to segments and cleans them in the specified order
+func (bot *Bot) cleanRoomsByName(names []string, iterations int) error {
+	rooms, err := bot.getRooms()
+	if err != nil {
+		return err
+	}
+
+	segmentIds := []string{}
+
+	for _, name := range names {
+		index := slices.IndexFunc(*rooms, func(room valetudo.RobotStateMapLayer) bool {
+			return strings.EqualFold(*room.Metadata.Name, name)
+		})
+
+		if index == -1 {
+			return fmt.Errorf("room %s not found", name)
+		}
+
+		segmentIds = append(segmentIds, *(*rooms)[index].Metadata.SegmentId)
+	}
+
+	return bot.cleanSegments(segmentIds, iterations)
+}
@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/storage"
+)
+
+func TestParseScheduleDays(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []int
+	}{
+		{value: "daily", expected: []int{0, 1, 2, 3, 4, 5, 6}},
+		{value: "everyday", expected: []int{0, 1, 2, 3, 4, 5, 6}},
+		{value: "weekdays", expected: []int{1, 2, 3, 4, 5}},
+		{value: "weekends", expected: []int{0, 6}},
+		{value: "mon", expected: []int{1}},
+		{value: "Mon", expected: []int{1}},
+		{value: "mon,wed,fri", expected: []int{1, 3, 5}},
+		{value: "mon-fri", expected: []int{1, 2, 3, 4, 5}},
+		{value: "fri-mon", expected: []int{0, 1, 5, 6}},
+		{value: "sat-sat", expected: []int{6}},
+		{value: "mon-wed,tue,sun", expected: []int{0, 1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			days, err := parseScheduleDays(test.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(days, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, days)
+			}
+		})
+	}
+}
+
+func TestParseScheduleDaysErrors(t *testing.T) {
+	for _, value := range []string{"", "monday", "mon-xyz", "mon,,fri"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseScheduleDays(value)
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestParseScheduledJob(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected scheduledJob
+	}{
+		{
+			args: "mon-fri 10:00 clean",
+			expected: scheduledJob{
+				Days: []int{1, 2, 3, 4, 5}, Hour: 10, Minute: 0,
+				Rooms: []string{}, Iterations: 1,
+			},
+		},
+		{
+			args: "sat 9:30 clean all 2x water=high mode=vacuum_and_mop",
+			expected: scheduledJob{
+				Days: []int{6}, Hour: 9, Minute: 30,
+				Rooms: []string{}, Iterations: 2,
+				WaterGrade: "high", Mode: "vacuum_and_mop",
+			},
+		},
+		{
+			args: "daily 22:15 clean Kitchen,Hallway fan=max",
+			expected: scheduledJob{
+				Days: []int{0, 1, 2, 3, 4, 5, 6}, Hour: 22, Minute: 15,
+				Rooms: []string{"Kitchen", "Hallway"}, Iterations: 1,
+				FanSpeed: "max",
+			},
+		},
+		{
+			args: "sun 07:05 clean Living room, Bedroom x3",
+			expected: scheduledJob{
+				Days: []int{0}, Hour: 7, Minute: 5,
+				Rooms: []string{"Living room", "Bedroom"}, Iterations: 3,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			job, err := parseScheduledJob(test.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(*job, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, *job)
+			}
+		})
+	}
+}
+
+func TestParseScheduledJobErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"mon 10:00",
+		"mon 10:00 mop",
+		"someday 10:00 clean",
+		"mon 25:00 clean",
+		"mon 10:00 clean all speed=fast",
+	}
+
+	for _, args := range tests {
+		t.Run(args, func(t *testing.T) {
+			_, err := parseScheduledJob(args)
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+// countingStorage counts writes, the schedule is checked often and shouldn't rewrite storage every time
+type countingStorage struct {
+	*storage.MemoryStorage
+	sets int
+}
+
+func (storage *countingStorage) Set(key string, value any) error {
+	storage.sets++
+	return storage.MemoryStorage.Set(key, value)
+}
+
+func TestTakeDueJobs(t *testing.T) {
+	store := &countingStorage{MemoryStorage: storage.NewMemoryStorage()}
+	bot := &Bot{storage: store}
+
+	// monday
+	now := time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC)
+
+	err := store.MemoryStorage.Set(scheduleStorageKey, scheduleState{Jobs: []scheduledJob{
+		{Id: 1, Days: []int{1}, Hour: 8, Minute: 30, SkipNext: true},
+		{Id: 2, Days: []int{2}, Hour: 8, Minute: 30},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected []int
+		sets     int
+	}{
+		{name: "nothing due", now: now.Add(-time.Minute), expected: []int{}, sets: 0},
+		{name: "due", now: now, expected: []int{1}, sets: 1},
+		{name: "already run", now: now.Add(20 * time.Second), expected: []int{}, sets: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			due, _, err := bot.takeDueJobs(test.now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ids := []int{}
+			for _, job := range due {
+				ids = append(ids, job.Id)
+			}
+
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("expected jobs %v, got %v", test.expected, ids)
+			}
+
+			if store.sets != test.sets {
+				t.Errorf("expected %d writes, got %d", test.sets, store.sets)
+			}
+		})
+	}
+
+	state, err := bot.loadSchedule()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if state.Jobs[0].SkipNext || !state.Jobs[0].LastRun.Equal(now) {
+		t.Errorf("expected the due job to be marked as run, got %+v", state.Jobs[0])
+	}
+}
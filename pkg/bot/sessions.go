@@ -0,0 +1,496 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo_map_renderer"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const sessionsStorageKey = "sessions"
+const activeSessionStorageKey = "active_session"
+
+// only this many sessions are kept, older ones are removed
+const maxStoredSessions = 100
+
+// target requested through the bot is assigned to a session only if it starts within this time
+const sessionTargetValidity = 5 * time.Minute
+
+const historyPageSize = 5
+
+const (
+	sessionEndCompleted = "completed"
+	sessionEndStopped   = "stopped"
+	sessionEndError     = "error"
+)
+
+type cleaningSession struct {
+	Id        int       `json:"id"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	/** rooms or zones requested, empty when cleaning wasn't started by the bot */
+	Target string `json:"target"`
+	/** duration reported by the robot, falls back to the time between start and end */
+	DurationSeconds int `json:"durationSeconds"`
+	/** cleaned area in m², nil when the robot doesn't report it */
+	Area          *float64 `json:"area,omitempty"`
+	BatteryStart  int      `json:"batteryStart"`
+	BatteryEnd    int      `json:"batteryEnd"`
+	FanSpeed      string   `json:"fanSpeed,omitempty"`
+	WaterGrade    string   `json:"waterGrade,omitempty"`
+	OperationMode string   `json:"operationMode,omitempty"`
+	EndReason     string   `json:"endReason,omitempty"`
+	/** path driven by the robot, same format as points of the path map entity */
+	Path []int `json:"path,omitempty"`
+}
+
+type sessionHistory struct {
+	Sessions []cleaningSession `json:"sessions"`
+	NextId   int               `json:"nextId"`
+}
+
+// setSessionTarget remembers what was requested, so the session that starts next can be labelled with it
+func (bot *Bot) setSessionTarget(target string) {
+	bot.sessionMutex.Lock()
+	defer bot.sessionMutex.Unlock()
+
+	bot.sessionTarget = target
+	bot.sessionTargetAt = time.Now()
+}
+
+func (bot *Bot) takeSessionTarget() string {
+	bot.sessionMutex.Lock()
+	defer bot.sessionMutex.Unlock()
+
+	target := bot.sessionTarget
+	bot.sessionTarget = ""
+
+	if time.Since(bot.sessionTargetAt) > sessionTargetValidity {
+		return ""
+	}
+
+	return target
+}
+
+// setSessionInterrupted remembers that the user ended the running session, the robot returning home isn't its completion then
+func (bot *Bot) setSessionInterrupted() {
+	bot.sessionMutex.Lock()
+	defer bot.sessionMutex.Unlock()
+
+	bot.sessionInterruptedAt = time.Now()
+}
+
+// takeSessionInterrupted tells if the user ended the session recently and forgets it
+func (bot *Bot) takeSessionInterrupted() bool {
+	bot.sessionMutex.Lock()
+	defer bot.sessionMutex.Unlock()
+
+	interrupted := time.Since(bot.sessionInterruptedAt) <= sessionTargetValidity
+	bot.sessionInterruptedAt = time.Time{}
+
+	return interrupted
+}
+
+// describeSegments returns names of the segments, segments without name are referenced by id
+func (bot *Bot) describeSegments(segmentIds []string) string {
+	rooms, err := bot.getRooms()
+	if err != nil {
+		log.Println(err)
+		rooms = &[]valetudo.RobotStateMapLayer{}
+	}
+
+	names := []string{}
+	for _, segmentId := range segmentIds {
+		name := "#" + segmentId
+
+		for _, room := range *rooms {
+			if *room.Metadata.SegmentId == segmentId {
+				name = *room.Metadata.Name
+				break
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func isSessionStatus(status string) bool {
+	return status == "cleaning" || status == "paused"
+}
+
+// getSessionEndReason tells why the session ended, interrupted is set when the user stopped the robot or sent it home through the bot
+func getSessionEndReason(previous *CurrentState, new *CurrentState, interrupted bool) string {
+	if new.Status == "error" {
+		return sessionEndError
+	}
+
+	// robot returns home by itself once it's done, otherwise it was stopped or sent home by the user
+	if !interrupted && previous.Status == "cleaning" && (new.Status == "returning" || new.Status == "docked") {
+		return sessionEndCompleted
+	}
+
+	return sessionEndStopped
+}
+
+// trackSession starts and finishes cleaning sessions based on status changes, returns session that just ended
+func (bot *Bot) trackSession(previous *CurrentState, new *CurrentState) *cleaningSession {
+	if !isSessionStatus(previous.Status) && new.Status == "cleaning" {
+		// home or stop sent before this session doesn't end it
+		bot.takeSessionInterrupted()
+
+		session := cleaningSession{
+			StartedAt:     time.Now(),
+			Target:        bot.takeSessionTarget(),
+			BatteryStart:  new.BatteryLevel,
+			FanSpeed:      new.FanSpeed,
+			WaterGrade:    new.WaterGrade,
+			OperationMode: new.OperationMode,
+		}
+
+		err := bot.storage.Set(activeSessionStorageKey, session)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to store cleaning session: %w", err))
+		}
+
+		return nil
+	}
+
+	if !isSessionStatus(previous.Status) || isSessionStatus(new.Status) {
+		return nil
+	}
+
+	session := cleaningSession{}
+	found, err := bot.storage.Get(activeSessionStorageKey, &session)
+	if err != nil || !found {
+		if err != nil {
+			log.Println(fmt.Errorf("failed to load cleaning session: %w", err))
+		}
+
+		return nil
+	}
+
+	session.EndedAt = time.Now()
+	session.EndReason = getSessionEndReason(previous, new, bot.takeSessionInterrupted())
+	session.BatteryEnd = new.BatteryLevel
+	session.DurationSeconds = int(session.EndedAt.Sub(session.StartedAt).Seconds())
+
+	bot.fillSessionStatistics(&session)
+
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get path of cleaning session: %w", err))
+	} else if path := state.Map.FindEntity("path"); path != nil && path.Points != nil {
+		session.Path = *path.Points
+	}
+
+	err = bot.saveSession(&session)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to store cleaning session: %w", err))
+	}
+
+	err = bot.storage.Delete(activeSessionStorageKey)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return &session
+}
+
+func (bot *Bot) fillSessionStatistics(session *cleaningSession) {
+	if !bot.HasCapability("CurrentStatisticsCapability") {
+		return
+	}
+
+	statistics, err := bot.robotApi.GetCurrentStatistics()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to get cleaning statistics: %w", err))
+		return
+	}
+
+	for _, point := range *statistics {
+		switch point.Type {
+		case "time":
+			if point.Value > 0 {
+				session.DurationSeconds = int(point.Value)
+			}
+		case "area":
+			area := point.Value / 10000
+			session.Area = &area
+		}
+	}
+}
+
+func (bot *Bot) getSessionHistory() (*sessionHistory, error) {
+	history := sessionHistory{Sessions: []cleaningSession{}, NextId: 1}
+
+	_, err := bot.storage.Get(sessionsStorageKey, &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+// saveSession assigns id to the session and stores it
+func (bot *Bot) saveSession(session *cleaningSession) error {
+	history, err := bot.getSessionHistory()
+	if err != nil {
+		return err
+	}
+
+	session.Id = history.NextId
+	history.NextId++
+	history.Sessions = append(history.Sessions, *session)
+
+	if len(history.Sessions) > maxStoredSessions {
+		history.Sessions = history.Sessions[len(history.Sessions)-maxStoredSessions:]
+	}
+
+	return bot.storage.Set(sessionsStorageKey, history)
+}
+
+func formatDuration(seconds int) string {
+	duration := time.Duration(seconds) * time.Second
+
+	if duration < time.Hour {
+		return fmt.Sprintf("%d min", int(duration.Minutes()))
+	}
+
+	return fmt.Sprintf("%d h %d min", int(duration.Hours()), int(duration.Minutes())%60)
+}
+
+func localizeSessionEndReason(reason string) string {
+	switch reason {
+	case sessionEndCompleted:
+		return "✅ Completed"
+	case sessionEndStopped:
+		return "⏹ Stopped"
+	case sessionEndError:
+		return "❌ Error"
+	}
+
+	return reason
+}
+
+func (bot *Bot) describeSession(session cleaningSession) string {
+	return fmt.Sprintf(
+		"%s %s\n%s",
+		localizeSessionEndReason(session.EndReason),
+		session.StartedAt.In(bot.timezone).Format("2006-01-02 15:04"),
+		describeSessionDetails(session),
+	)
+}
+
+// describeSessionDetails lists what was cleaned and how, one value per line
+func describeSessionDetails(session cleaningSession) string {
+	target := session.Target
+	if target == "" {
+		target = "Not started by the bot"
+	}
+
+	lines := []string{
+		"🏠 " + target,
+		"⏱ " + formatDuration(session.DurationSeconds),
+	}
+
+	if session.Area != nil {
+		lines = append(lines, fmt.Sprintf("📐 %.1f m²", *session.Area))
+	}
+
+	lines = append(lines, fmt.Sprintf("🔋 %d %% used", max(session.BatteryStart-session.BatteryEnd, 0)))
+
+	settings := []string{}
+	if session.OperationMode != "" {
+		settings = append(settings, localizeOperationMode(session.OperationMode))
+	}
+	if session.FanSpeed != "" {
+		settings = append(settings, "fan "+localizeFanSpeed(session.FanSpeed))
+	}
+	if session.WaterGrade != "" {
+		settings = append(settings, "water "+localizeWaterGrade(session.WaterGrade))
+	}
+
+	if len(settings) > 0 {
+		lines = append(lines, "⚙️ "+strings.Join(settings, ", "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderSessionMap renders current map with path of the session instead of the current one
+func (bot *Bot) renderSessionMap(session cleaningSession) ([]byte, error) {
+	state, err := bot.robotApi.GetRobotState()
+	if err != nil {
+		return nil, err
+	}
+
+	robotMap := state.Map
+	robotMap.Entities = slices.DeleteFunc(slices.Clone(robotMap.Entities), func(entity valetudo.RobotStateMapEntity) bool {
+		return entity.Type == "path" || entity.Type == "predicted_path"
+	})
+
+	if len(session.Path) > 0 {
+		path := slices.Clone(session.Path)
+		robotMap.Entities = append(robotMap.Entities, valetudo.RobotStateMapEntity{
+			Class:  valetudo.PathMapEntityClass,
+			Type:   valetudo.MapEntityPath,
+			Points: &path,
+		})
+	}
+
+	return valetudo_map_renderer.RenderMapWithOptions(&robotMap, bot.getMapRenderOptions()), nil
+}
+
+// sendCompletionReport notifies users about finished cleaning with the driven path on the map
+func (bot *Bot) sendCompletionReport(session cleaningSession) {
+	caption := "✅ Cleaning complete\n" + describeSessionDetails(session)
+
+	image, err := bot.renderSessionMap(session)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to render completion report map: %w", err))
+		bot.notify(notificationReports, caption, nil)
+
+		return
+	}
+
+	bot.notifyPhoto(notificationReports, image, caption)
+}
+
+func (bot *Bot) buildHistoryMessage(page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	history, err := bot.getSessionHistory()
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	if len(history.Sessions) == 0 {
+		return "📜 No cleaning sessions recorded yet", tgbotapi.NewInlineKeyboardMarkup(), nil
+	}
+
+	pages := (len(history.Sessions) + historyPageSize - 1) / historyPageSize
+	page = max(0, min(page, pages-1))
+
+	text := fmt.Sprintf("📜 Cleaning history, page %d/%d", page+1, pages)
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	// newest sessions first
+	for i := 0; i < historyPageSize; i++ {
+		index := len(history.Sessions) - 1 - page*historyPageSize - i
+		if index < 0 {
+			break
+		}
+
+		session := history.Sessions[index]
+		text += "\n\n" + bot.describeSession(session)
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("🗺 %s", session.StartedAt.In(bot.timezone).Format("2006-01-02 15:04")),
+				fmt.Sprintf("history show %d", session.Id),
+			),
+		))
+	}
+
+	navigation := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("⬅️ Newer", fmt.Sprintf("history page %d", page-1)))
+	}
+	if page < pages-1 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("Older ➡️", fmt.Sprintf("history page %d", page+1)))
+	}
+
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) handleHistoryCommand(requesterId int64, args string) error {
+	text, keyboard, err := bot.buildHistoryMessage(0)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	if len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
+	}
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) handleHistoryCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) < 2 {
+		return nil
+	}
+
+	value, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "page":
+		callback := tgbotapi.NewCallback(query.ID, "")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			return err
+		}
+
+		text, keyboard, err := bot.buildHistoryMessage(value)
+		if err != nil {
+			return err
+		}
+
+		_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, keyboard))
+
+		return err
+
+	case "show":
+		history, err := bot.getSessionHistory()
+		if err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(history.Sessions, func(session cleaningSession) bool {
+			return session.Id == value
+		})
+
+		if index == -1 {
+			return fmt.Errorf("session not found, it may have been removed from history")
+		}
+
+		callback := tgbotapi.NewCallback(query.ID, "")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			return err
+		}
+
+		session := history.Sessions[index]
+
+		image, err := bot.renderSessionMap(session)
+		if err != nil {
+			return err
+		}
+
+		msg := tgbotapi.NewPhoto(query.Message.Chat.ID, tgbotapi.FileBytes{
+			Name:  "map.png",
+			Bytes: image,
+		})
+		msg.Caption = bot.describeSession(session)
+
+		_, err = bot.telegramApi.Send(msg)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,450 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// timerDraft holds a timer being created through the /timers keyboard
+type timerDraft struct {
+	Dow        []int
+	SegmentIds []string
+}
+
+// week starts on monday in the keyboard, valetudo uses 0 for sunday
+var timerKeyboardDays = []int{1, 2, 3, 4, 5, 6, 0}
+
+// convertTimerTime shifts timer days and time from one location to another.
+// Offset of the current week is used, so DST is applied as it is right now.
+func convertTimerTime(dow []int, hour int, minute int, from *time.Location, to *time.Location) ([]int, int, int) {
+	now := time.Now().In(from)
+	sunday := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), hour, minute, 0, 0, from)
+
+	result := []int{}
+	resultHour := hour
+	resultMinute := minute
+
+	for _, day := range dow {
+		converted := sunday.AddDate(0, 0, day).In(to)
+		result = append(result, int(converted.Weekday()))
+		resultHour = converted.Hour()
+		resultMinute = converted.Minute()
+	}
+
+	sort.Ints(result)
+
+	return result, resultHour, resultMinute
+}
+
+func formatTimerDays(dow []int) string {
+	if len(dow) == 7 {
+		return "Every day"
+	}
+
+	days := []string{}
+	for _, day := range timerKeyboardDays {
+		if slices.Contains(dow, day) {
+			days = append(days, time.Weekday(day).String()[:3])
+		}
+	}
+
+	return strings.Join(days, ", ")
+}
+
+func (bot *Bot) describeTimer(timer valetudo.ValetudoTimer) string {
+	dow, hour, minute := convertTimerTime(timer.Dow, timer.Hour, timer.Minute, time.UTC, bot.timezone)
+
+	icon := "✅"
+	if !timer.Enabled {
+		icon = "⏸"
+	}
+
+	target := "Everything"
+	if timer.Action.Type == "segment_cleanup" {
+		target = strings.Join(bot.getRoomNames(timer.Action.Params.SegmentIds), ", ")
+	}
+
+	if timer.Action.Params.Iterations != nil {
+		target += formatIterations(*timer.Action.Params.Iterations)
+	}
+
+	description := fmt.Sprintf("%s %s %02d:%02d – %s", icon, formatTimerDays(dow), hour, minute, target)
+	if timer.Label != "" {
+		description += " (" + timer.Label + ")"
+	}
+
+	return description
+}
+
+func (bot *Bot) buildTimersMessage() (string, tgbotapi.InlineKeyboardMarkup, error) {
+	timers, err := bot.robotApi.GetTimers()
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	sort.Slice(*timers, func(i, j int) bool {
+		a := (*timers)[i]
+		b := (*timers)[j]
+
+		if a.Hour != b.Hour {
+			return a.Hour < b.Hour
+		}
+
+		if a.Minute != b.Minute {
+			return a.Minute < b.Minute
+		}
+
+		return a.Id < b.Id
+	})
+
+	text := "⏰ Timers:"
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	for i, timer := range *timers {
+		text += fmt.Sprintf("\n%d. %s", i+1, bot.describeTimer(timer))
+
+		toggleLabel := fmt.Sprintf("⏸ Disable %d.", i+1)
+		if !timer.Enabled {
+			toggleLabel = fmt.Sprintf("▶️ Enable %d.", i+1)
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(toggleLabel, "timers toggle "+timer.Id),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Delete %d.", i+1), "timers delete "+timer.Id),
+		))
+	}
+
+	if len(*timers) == 0 {
+		text = "⏰ There are no timers yet"
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ New timer", "timers new"),
+	))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func buildTimerDaysKeyboard(draft *timerDraft) tgbotapi.InlineKeyboardMarkup {
+	row := []tgbotapi.InlineKeyboardButton{}
+
+	for _, day := range timerKeyboardDays {
+		label := time.Weekday(day).String()[:2]
+		if slices.Contains(draft.Dow, day) {
+			label = "✅" + label
+		}
+
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("timers day %d", day)))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Cancel", "timers list"),
+			tgbotapi.NewInlineKeyboardButtonData("➡️ Next", "timers days_done"),
+		),
+	)
+}
+
+func buildTimerRoomsKeyboard(draft *timerDraft, rooms []valetudo.RobotStateMapLayer) tgbotapi.InlineKeyboardMarkup {
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return strings.Compare(*rooms[i].Metadata.Name, *rooms[j].Metadata.Name) < 0
+	})
+
+	for _, room := range rooms {
+		label := "⬜ " + *room.Metadata.Name
+		if index := slices.Index(draft.SegmentIds, *room.Metadata.SegmentId); index != -1 {
+			label = fmt.Sprintf("✅ %d. %s", index+1, *room.Metadata.Name)
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "timers room "+*room.Metadata.SegmentId),
+		))
+	}
+
+	nextLabel := "➡️ Next (everything)"
+	if len(draft.SegmentIds) > 0 {
+		nextLabel = fmt.Sprintf("➡️ Next (%d rooms)", len(draft.SegmentIds))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("↩️ Cancel", "timers list"),
+		tgbotapi.NewInlineKeyboardButtonData(nextLabel, "timers rooms_done"),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
+func (bot *Bot) handleTimersCommand(requesterId int64, args string) error {
+	text, keyboard, err := bot.buildTimersMessage()
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	msg.ReplyMarkup = keyboard
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) editTimersMessage(query *tgbotapi.CallbackQuery, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+	_, err := bot.telegramApi.Request(edit)
+
+	return err
+}
+
+func (bot *Bot) refreshTimersMessage(query *tgbotapi.CallbackQuery) error {
+	text, keyboard, err := bot.buildTimersMessage()
+	if err != nil {
+		return err
+	}
+
+	return bot.editTimersMessage(query, text, keyboard)
+}
+
+func (bot *Bot) findTimer(id string) (*valetudo.ValetudoTimer, error) {
+	timers, err := bot.robotApi.GetTimers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, timer := range *timers {
+		if timer.Id == id {
+			return &timer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("timer not found")
+}
+
+func (bot *Bot) handleTimersCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	chatId := query.Message.Chat.ID
+	draft, hasDraft := bot.timerDrafts[chatId]
+
+	switch args[0] {
+	case "list":
+		delete(bot.timerDrafts, chatId)
+		return bot.refreshTimersMessage(query)
+
+	case "toggle":
+		if len(args) < 2 {
+			return nil
+		}
+
+		timer, err := bot.findTimer(args[1])
+		if err != nil {
+			return err
+		}
+
+		timer.Enabled = !timer.Enabled
+
+		err = bot.robotApi.UpdateTimer(*timer)
+		if err != nil {
+			return err
+		}
+
+		return bot.refreshTimersMessage(query)
+
+	case "delete":
+		if len(args) < 2 {
+			return nil
+		}
+
+		timer, err := bot.findTimer(args[1])
+		if err != nil {
+			return err
+		}
+
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Yes, delete", "timers confirm_delete "+timer.Id),
+				tgbotapi.NewInlineKeyboardButtonData("↩️ Cancel", "timers list"),
+			),
+		)
+
+		return bot.editTimersMessage(query, "Do you really want to delete this timer?\n"+bot.describeTimer(*timer), keyboard)
+
+	case "confirm_delete":
+		if len(args) < 2 {
+			return nil
+		}
+
+		err := bot.robotApi.DeleteTimer(args[1])
+		if err != nil {
+			return err
+		}
+
+		return bot.refreshTimersMessage(query)
+
+	case "new":
+		properties, err := bot.robotApi.GetTimersProperties()
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(properties.SupportedActions, "full_cleanup") && !slices.Contains(properties.SupportedActions, "segment_cleanup") {
+			return fmt.Errorf("robot doesn't support cleanup timers")
+		}
+
+		draft = &timerDraft{}
+		bot.timerDrafts[chatId] = draft
+
+		return bot.editTimersMessage(query, "⏰ New timer: on which days?", buildTimerDaysKeyboard(draft))
+	}
+
+	// everything below is part of the new timer flow
+	if !hasDraft {
+		return bot.refreshTimersMessage(query)
+	}
+
+	switch args[0] {
+	case "day":
+		if len(args) < 2 {
+			return nil
+		}
+
+		day, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		if index := slices.Index(draft.Dow, day); index != -1 {
+			draft.Dow = slices.Delete(draft.Dow, index, index+1)
+		} else {
+			draft.Dow = append(draft.Dow, day)
+		}
+
+		_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, query.Message.MessageID, buildTimerDaysKeyboard(draft)))
+
+		return err
+
+	case "days_done":
+		if len(draft.Dow) == 0 {
+			_, err := bot.telegramApi.Request(tgbotapi.NewCallback(query.ID, "Pick at least one day"))
+			return err
+		}
+
+		rooms, err := bot.getRooms()
+		if err != nil {
+			return err
+		}
+
+		return bot.editTimersMessage(query, "⏰ New timer: what should be cleaned?", buildTimerRoomsKeyboard(draft, *rooms))
+
+	case "room":
+		if len(args) < 2 {
+			return nil
+		}
+
+		if index := slices.Index(draft.SegmentIds, args[1]); index != -1 {
+			draft.SegmentIds = slices.Delete(draft.SegmentIds, index, index+1)
+		} else {
+			draft.SegmentIds = append(draft.SegmentIds, args[1])
+		}
+
+		rooms, err := bot.getRooms()
+		if err != nil {
+			return err
+		}
+
+		_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageReplyMarkup(chatId, query.Message.MessageID, buildTimerRoomsKeyboard(draft, *rooms)))
+
+		return err
+
+	case "rooms_done":
+		messageId := query.Message.MessageID
+
+		err := bot.editTimersMessage(query, "⏰ New timer: waiting for time", tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("↩️ Cancel", "timers list")),
+		))
+		if err != nil {
+			log.Println(err)
+		}
+
+		return bot.prompt(query, "⏰ At what time? Send it as HH:MM", func(chatId int64, text string) error {
+			return bot.createTimerFromDraft(chatId, messageId, text)
+		})
+	}
+
+	return nil
+}
+
+func (bot *Bot) createTimerFromDraft(chatId int64, messageId int, text string) error {
+	draft, ok := bot.timerDrafts[chatId]
+	if !ok {
+		return fmt.Errorf("timer creation was cancelled")
+	}
+
+	parsed, err := time.Parse("15:04", text)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid time, expected HH:MM", text)
+	}
+
+	dow, hour, minute := convertTimerTime(draft.Dow, parsed.Hour(), parsed.Minute(), bot.timezone, time.UTC)
+
+	timer := valetudo.ValetudoTimer{
+		Enabled: true,
+		Dow:     dow,
+		Hour:    hour,
+		Minute:  minute,
+		Action: valetudo.ValetudoTimerAction{
+			Type: "full_cleanup",
+		},
+	}
+
+	if len(draft.SegmentIds) > 0 {
+		properties, err := bot.robotApi.GetMapSegmentationCapabilityProperties()
+		if err != nil {
+			return err
+		}
+
+		customOrder := properties.CustomOrderSupport && len(draft.SegmentIds) > 1
+		iterations := 1
+
+		timer.Action = valetudo.ValetudoTimerAction{
+			Type: "segment_cleanup",
+			Params: valetudo.ValetudoTimerActionParams{
+				SegmentIds:  draft.SegmentIds,
+				Iterations:  &iterations,
+				CustomOrder: &customOrder,
+			},
+		}
+	}
+
+	err = bot.robotApi.CreateTimer(timer)
+	if err != nil {
+		return err
+	}
+
+	delete(bot.timerDrafts, chatId)
+
+	// replace the wizard message with updated list
+	text, keyboard, err := bot.buildTimersMessage()
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageTextAndMarkup(chatId, messageId, text, keyboard))
+	if err != nil {
+		log.Println(err)
+	}
+
+	return bot.Send(chatId, "✅ Timer created: "+bot.describeTimer(timer))
+}
@@ -0,0 +1,504 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const usersStorageKey = "users"
+const invitesStorageKey = "invites"
+
+const defaultInviteValidity = 24 * time.Hour
+
+// storedUser is a user added at runtime, users from configuration aren't stored
+type storedUser struct {
+	Id      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Role    userRole  `json:"role"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+type userInvite struct {
+	Token     string    `json:"token"`
+	Role      userRole  `json:"role"`
+	CreatedBy int64     `json:"createdBy"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// getUserIds returns copy of all allowed user ids, safe to iterate while users are being changed
+func (bot *Bot) getUserIds() []int64 {
+	bot.usersMutex.RLock()
+	defer bot.usersMutex.RUnlock()
+
+	return slices.Clone(bot.chatIds)
+}
+
+func (bot *Bot) isConfigUser(id int64) bool {
+	bot.usersMutex.RLock()
+	defer bot.usersMutex.RUnlock()
+
+	return slices.Contains(bot.configUserIds, id)
+}
+
+// setUserRoleInMemory allows the user to use the bot, expects the users mutex to be held
+func (bot *Bot) setUserRoleInMemory(id int64, role userRole) {
+	if !slices.Contains(bot.chatIds, id) {
+		bot.chatIds = append(bot.chatIds, id)
+	}
+
+	bot.userRoles[id] = role
+}
+
+func (bot *Bot) getStoredUsers() ([]storedUser, error) {
+	users := []storedUser{}
+
+	_, err := bot.sharedStorage.Get(usersStorageKey, &users)
+	if err != nil {
+		return nil, err
+	}
+
+	// users stored before roles were introduced
+	for i := range users {
+		if users[i].Role == "" {
+			users[i].Role = defaultUserRole
+		}
+	}
+
+	return users, nil
+}
+
+// loadStoredUsers adds users stored at runtime to the ones from configuration
+func (bot *Bot) loadStoredUsers() error {
+	users, err := bot.getStoredUsers()
+	if err != nil {
+		return err
+	}
+
+	bot.usersMutex.Lock()
+	defer bot.usersMutex.Unlock()
+
+	for _, user := range users {
+		if !slices.Contains(bot.configUserIds, user.Id) {
+			bot.setUserRoleInMemory(user.Id, user.Role)
+		}
+	}
+
+	return nil
+}
+
+// saveStoredUser adds the user or updates existing one
+func (bot *Bot) saveStoredUser(user storedUser) error {
+	if bot.isConfigUser(user.Id) {
+		return fmt.Errorf("user %d is set in configuration and can't be changed", user.Id)
+	}
+
+	users, err := bot.getStoredUsers()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range users {
+		if users[i].Id == user.Id {
+			if user.Name == "" {
+				user.Name = users[i].Name
+			}
+
+			user.AddedAt = users[i].AddedAt
+			users[i] = user
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		user.AddedAt = time.Now()
+		users = append(users, user)
+	}
+
+	err = bot.sharedStorage.Set(usersStorageKey, users)
+	if err != nil {
+		return err
+	}
+
+	bot.usersMutex.Lock()
+	bot.setUserRoleInMemory(user.Id, user.Role)
+	bot.usersMutex.Unlock()
+
+	return bot.publishUserCommands(user.Id)
+}
+
+func (bot *Bot) removeStoredUser(id int64) error {
+	if bot.isConfigUser(id) {
+		return fmt.Errorf("user %d is set in configuration and can't be removed", id)
+	}
+
+	users, err := bot.getStoredUsers()
+	if err != nil {
+		return err
+	}
+
+	result := []storedUser{}
+	for _, user := range users {
+		if user.Id != id {
+			result = append(result, user)
+		}
+	}
+
+	if len(result) == len(users) {
+		return fmt.Errorf("user %d not found", id)
+	}
+
+	err = bot.sharedStorage.Set(usersStorageKey, result)
+	if err != nil {
+		return err
+	}
+
+	bot.usersMutex.Lock()
+	bot.chatIds = slices.DeleteFunc(bot.chatIds, func(userId int64) bool {
+		return userId == id
+	})
+	delete(bot.userRoles, id)
+	bot.usersMutex.Unlock()
+
+	return bot.publishUserCommands(id)
+}
+
+func (bot *Bot) changeUserRole(id int64, role userRole) error {
+	users, err := bot.getStoredUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.Id == id {
+			user.Role = role
+			return bot.saveStoredUser(user)
+		}
+	}
+
+	return fmt.Errorf("user %d not found", id)
+}
+
+func describeTelegramUser(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+func (bot *Bot) buildUsersMessage() (string, tgbotapi.InlineKeyboardMarkup, error) {
+	users, err := bot.getStoredUsers()
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	bot.usersMutex.RLock()
+	configUsers := slices.Clone(bot.configUserIds)
+	bot.usersMutex.RUnlock()
+
+	text := "👥 Users\n"
+	for _, id := range configUsers {
+		text += fmt.Sprintf("\n%d - %s (configuration)", id, localizeUserRole(roleAdmin))
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, user := range users {
+		label := fmt.Sprintf("%d", user.Id)
+		if user.Name != "" {
+			label = user.Name + " (" + label + ")"
+		}
+
+		text += "\n" + label + " - " + localizeUserRole(user.Role)
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localizeUserRole(user.Role)+" "+label, fmt.Sprintf("users role %d", user.Id)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑", fmt.Sprintf("users remove %d", user.Id)),
+		))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔗 Create invite link", "users invite"),
+	))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(keyboard...), nil
+}
+
+func (bot *Bot) handleUsersCommand(requesterId int64, args string) error {
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch subcommand {
+	case "add":
+		idArg, rest, _ := strings.Cut(rest, " ")
+		rest = strings.TrimSpace(rest)
+
+		id, err := strconv.ParseInt(idArg, 10, 64)
+		if err != nil {
+			bot.Send(requesterId, "Usage: /users add <id> [viewer|operator|admin] [name]")
+			return nil
+		}
+
+		role := defaultUserRole
+		roleArg, name, _ := strings.Cut(rest, " ")
+		if parsed, ok := parseUserRole(roleArg); ok {
+			role = parsed
+			rest = strings.TrimSpace(name)
+		}
+
+		err = bot.saveStoredUser(storedUser{Id: id, Name: rest, Role: role})
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(requesterId, fmt.Sprintf("👤 User %d added as %s", id, localizeUserRole(role)))
+
+	case "role":
+		fields := strings.Fields(rest)
+		if len(fields) != 2 {
+			bot.Send(requesterId, "Usage: /users role <id> <viewer|operator|admin>")
+			return nil
+		}
+
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+		role, ok := parseUserRole(fields[1])
+		if err != nil || !ok {
+			bot.Send(requesterId, "Usage: /users role <id> <viewer|operator|admin>")
+			return nil
+		}
+
+		err = bot.changeUserRole(id, role)
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(requesterId, fmt.Sprintf("👤 User %d is now %s", id, localizeUserRole(role)))
+
+	case "remove", "delete":
+		id, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			bot.Send(requesterId, "Usage: /users remove <id>")
+			return nil
+		}
+
+		err = bot.removeStoredUser(id)
+		if err != nil {
+			return err
+		}
+
+		return bot.Send(requesterId, fmt.Sprintf("🗑 User %d removed", id))
+
+	case "invite":
+		validity := defaultInviteValidity
+		role := defaultUserRole
+
+		for _, field := range strings.Fields(rest) {
+			if parsed, ok := parseUserRole(field); ok {
+				role = parsed
+				continue
+			}
+
+			hours, err := strconv.Atoi(strings.TrimSuffix(field, "h"))
+			if err != nil || hours <= 0 {
+				bot.Send(requesterId, "Usage: /users invite [hours] [viewer|operator|admin]")
+				return nil
+			}
+
+			validity = time.Duration(hours) * time.Hour
+		}
+
+		return bot.sendInvite(requesterId, validity, role)
+	}
+
+	text, keyboard, err := bot.buildUsersMessage()
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, text)
+	msg.ReplyMarkup = keyboard
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
+
+func (bot *Bot) getInvites() ([]userInvite, error) {
+	invites := []userInvite{}
+
+	_, err := bot.sharedStorage.Get(invitesStorageKey, &invites)
+	if err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+func (bot *Bot) sendInvite(requesterId int64, validity time.Duration, role userRole) error {
+	// telegram allows only A-Z, a-z, 0-9, _ and - in the start parameter
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return err
+	}
+
+	invite := userInvite{
+		Token:     base64.RawURLEncoding.EncodeToString(tokenBytes),
+		Role:      role,
+		CreatedBy: requesterId,
+		ExpiresAt: time.Now().Add(validity),
+	}
+
+	bot.invitesMutex.Lock()
+	defer bot.invitesMutex.Unlock()
+
+	invites, err := bot.getInvites()
+	if err != nil {
+		return err
+	}
+
+	invites = slices.DeleteFunc(invites, func(existing userInvite) bool {
+		return existing.ExpiresAt.Before(time.Now())
+	})
+	invites = append(invites, invite)
+
+	err = bot.sharedStorage.Set(invitesStorageKey, invites)
+	if err != nil {
+		return err
+	}
+
+	return bot.Send(requesterId, fmt.Sprintf(
+		"🔗 One-time invite link for %s, valid until %s:\nhttps://t.me/%s?start=%s",
+		localizeUserRole(role),
+		invite.ExpiresAt.In(bot.timezone).Format("2006-01-02 15:04"),
+		bot.telegramApi.Self.UserName,
+		invite.Token,
+	))
+}
+
+// redeemInvite registers sender of /start <token> message, returns false when the token isn't valid
+func (bot *Bot) redeemInvite(message *tgbotapi.Message) bool {
+	token := strings.TrimSpace(message.CommandArguments())
+	if token == "" {
+		return false
+	}
+
+	bot.invitesMutex.Lock()
+	defer bot.invitesMutex.Unlock()
+
+	invites, err := bot.getInvites()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	var invite *userInvite
+	remaining := []userInvite{}
+
+	for i := range invites {
+		if invites[i].ExpiresAt.Before(time.Now()) {
+			continue
+		}
+
+		if invites[i].Token == token {
+			invite = &invites[i]
+			continue
+		}
+
+		remaining = append(remaining, invites[i])
+	}
+
+	if invite == nil {
+		return false
+	}
+
+	err = bot.sharedStorage.Set(invitesStorageKey, remaining)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	role := invite.Role
+	if role == "" {
+		role = defaultUserRole
+	}
+
+	name := describeTelegramUser(message.From)
+
+	err = bot.saveStoredUser(storedUser{Id: message.From.ID, Name: name, Role: role})
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	bot.Send(message.Chat.ID, "👋 Welcome! You can now use this bot as "+localizeUserRole(role)+", try /status")
+	bot.Send(invite.CreatedBy, fmt.Sprintf("👤 %s (%d) joined using your invite link", name, message.From.ID))
+
+	return true
+}
+
+func (bot *Bot) handleUsersCallback(query *tgbotapi.CallbackQuery, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	response := ""
+
+	switch args[0] {
+	case "invite":
+		callback := tgbotapi.NewCallback(query.ID, "")
+		if _, err := bot.telegramApi.Request(callback); err != nil {
+			return err
+		}
+
+		return bot.sendInvite(query.Message.Chat.ID, defaultInviteValidity, defaultUserRole)
+
+	case "role", "remove":
+		if len(args) < 2 {
+			return nil
+		}
+
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if args[0] == "remove" {
+			err = bot.removeStoredUser(id)
+			response = fmt.Sprintf("🗑 User %d removed", id)
+		} else {
+			role := bot.getUserRole(id).next()
+			err = bot.changeUserRole(id, role)
+			response = fmt.Sprintf("User %d is now %s", id, localizeUserRole(role))
+		}
+
+		if err != nil {
+			return err
+		}
+
+	default:
+		return nil
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, response)
+	if _, err := bot.telegramApi.Request(callback); err != nil {
+		return err
+	}
+
+	text, keyboard, err := bot.buildUsersMessage()
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.telegramApi.Request(tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, keyboard))
+
+	return err
+}
@@ -1,15 +1,33 @@
 package bot
 
 import (
+	"fmt"
+	"log"
+	"strings"
+
 	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo_map_renderer"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const capabilitiesStorageKey = "capabilities"
+const lastStateStorageKey = "last_state"
+
+// names of saved zones and points are used in callbacks, they have to fit there with the longest command prefix and robot tag
+const maxSavedNameLength = maxCallbackDataLength - maxCallbackRobotTagLength - len("goto ")
+
 type CurrentStateAttachmentState struct {
 	Type     string
 	Attached bool
 }
 
+type CurrentStateConsumable struct {
+	Type    string
+	SubType string
+	Value   int
+	Unit    string
+}
+
 type CurrentState struct {
 	BatteryStatus       string
 	BatteryLevel        int
@@ -19,6 +37,48 @@ type CurrentState struct {
 	FanSpeed            string
 	Attachments         []CurrentStateAttachmentState
 	AttachedAttachments []string
+	Consumables         []CurrentStateConsumable
+	/** additional status info, for example "resumable" or "segment" */
+	StatusFlag string
+	/** details of the error, only set in error status */
+	Error *valetudo.ValetudoRobotError
+	/** status of auto-empty or mop washing dock, empty if the robot doesn't have one */
+	DockStatus string
+}
+
+// loadCapabilities fetches robot capabilities, falls back to the last known ones when robot is unreachable
+func (bot *Bot) loadCapabilities() error {
+	capabilities, err := bot.robotApi.GetRobotCapabilities()
+
+	if err != nil {
+		cached := []string{}
+		found, storageErr := bot.storage.Get(capabilitiesStorageKey, &cached)
+
+		if storageErr != nil || !found {
+			return fmt.Errorf("failed to get robot capabilities: %w", err)
+		}
+
+		log.Println(fmt.Errorf("failed to get robot capabilities, using last known ones: %w", err))
+		bot.capabilities = cached
+
+		return nil
+	}
+
+	bot.capabilities = *capabilities
+
+	err = bot.storage.Set(capabilitiesStorageKey, bot.capabilities)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to store capabilities: %w", err))
+	}
+
+	return nil
+}
+
+func (bot *Bot) saveLastState(state *CurrentState) {
+	err := bot.storage.Set(lastStateStorageKey, state)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to store last state: %w", err))
+	}
 }
 
 func (bot *Bot) getParsedState() (*CurrentState, error) {
@@ -49,56 +109,172 @@ func (bot *Bot) getRooms() (*[]valetudo.RobotStateMapLayer, error) {
 	return &result, nil
 }
 
-func stateObjToData(state *[]valetudo.RobotStateAttribute) *CurrentState {
+func stateObjToData(state *valetudo.RobotStateAttributes) *CurrentState {
 	result := CurrentState{}
 
-	for _, attribute := range *state {
-		if attribute.Class == "BatteryStateAttribute" {
-			if attribute.Flag != nil {
-				result.BatteryStatus = *attribute.Flag
-			}
-			if attribute.Level != nil {
-				result.BatteryLevel = *attribute.Level
-			}
+	if battery := state.Battery(); battery != nil {
+		result.BatteryStatus = battery.Flag
+		result.BatteryLevel = battery.Level
+	}
+
+	if status := state.Status(); status != nil {
+		result.Status = status.Value
+		result.StatusFlag = status.Flag
+		result.Error = status.Error
+	}
+
+	if dock := state.DockStatus(); dock != nil {
+		result.DockStatus = dock.Value
+	}
+
+	for _, attachment := range state.Attachments() {
+		result.Attachments = append(result.Attachments, CurrentStateAttachmentState{
+			Type:     attachment.Type,
+			Attached: attachment.Attached,
+		})
+
+		if attachment.Attached {
+			result.AttachedAttachments = append(result.AttachedAttachments, attachment.Type)
 		}
+	}
 
-		if attribute.Class == "StatusStateAttribute" {
-			if attribute.Value != nil {
-				result.Status = *attribute.Value
-			}
+	for _, attribute := range state.Consumables() {
+		if consumable := attributeToConsumable(attribute); consumable != nil {
+			result.Consumables = append(result.Consumables, *consumable)
 		}
+	}
+
+	if preset := state.Preset(valetudo.PresetWaterGrade); preset != nil {
+		result.WaterGrade = preset.Value
+	}
+
+	if preset := state.Preset(valetudo.PresetOperationMode); preset != nil {
+		result.OperationMode = preset.Value
+	}
+
+	if preset := state.Preset(valetudo.PresetFanSpeed); preset != nil {
+		result.FanSpeed = preset.Value
+	}
+
+	return &result
+}
+
+// getMapRenderOptions returns overlays that should be drawn on every map sent by the bot
+func (bot *Bot) getMapRenderOptions() valetudo_map_renderer.RenderOptions {
+	options := valetudo_map_renderer.RenderOptions{}
+
+	markers, err := bot.getPointMarkers()
+	if err != nil {
+		log.Println(err)
+	} else {
+		options.Markers = append(options.Markers, markers...)
+	}
+
+	return options
+}
+
+func (bot *Bot) renderMap(robotMap *valetudo.RobotStateMap) []byte {
+	return valetudo_map_renderer.RenderMapWithOptions(robotMap, bot.getMapRenderOptions())
+}
+
+func attributeToConsumable(attribute valetudo.ConsumableStateAttribute) *CurrentStateConsumable {
+	if attribute.Remaining.Value == nil || attribute.Remaining.Unit == nil {
+		return nil
+	}
+
+	return &CurrentStateConsumable{
+		Type:    attribute.Type,
+		SubType: attribute.SubType,
+		Value:   *attribute.Remaining.Value,
+		Unit:    *attribute.Remaining.Unit,
+	}
+}
+
+// promptHandler receives text the user replied with to a question asked using bot.prompt
+type promptHandler func(chatId int64, text string) error
+
+type promptKey struct {
+	chatId int64
+	userId int64
+}
+
+type pendingPrompt struct {
+	/** role needed by the button that asked, checked again when the answer arrives */
+	required  userRole
+	messageId int
+	handler   promptHandler
+}
+
+// prompt asks user who pressed the button for a text input, their next non-command message in the chat is passed to the handler
+func (bot *Bot) prompt(query *tgbotapi.CallbackQuery, question string, handler promptHandler) error {
+	chatId := query.Message.Chat.ID
+
+	msg := tgbotapi.NewMessage(chatId, question)
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+
+	sent, err := bot.telegramApi.Send(msg)
+	if err != nil {
+		return err
+	}
+
+	bot.pendingPrompts[promptKey{chatId: chatId, userId: query.From.ID}] = pendingPrompt{
+		required:  requiredRole(callbackRoles, strings.Split(query.Data, " ")),
+		messageId: sent.MessageID,
+		handler:   handler,
+	}
+
+	return nil
+}
+
+// handlePrompt passes message to pending prompt of its sender, returns false if there's none
+func (bot *Bot) handlePrompt(message *tgbotapi.Message, role userRole) bool {
+	if message.From == nil {
+		return false
+	}
+
+	key := promptKey{chatId: message.Chat.ID, userId: message.From.ID}
 
-		if attribute.Class == "AttachmentStateAttribute" {
-			if attribute.Type != nil && attribute.Attached != nil {
-				result.Attachments = append(result.Attachments, CurrentStateAttachmentState{
-					Type:     *attribute.Type,
-					Attached: *attribute.Attached,
-				})
-
-				if *attribute.Attached {
-					result.AttachedAttachments = append(result.AttachedAttachments, *attribute.Type)
-				}
-			}
+	prompt, ok := bot.pendingPrompts[key]
+	if !ok {
+		// somebody else is answering the question
+		if !message.IsCommand() && message.ReplyToMessage != nil && bot.isPromptMessage(message.Chat.ID, message.ReplyToMessage.MessageID) {
+			bot.Send(message.Chat.ID, "⛔ This question is for someone else")
+			return true
 		}
 
-		if attribute.Class == "PresetSelectionStateAttribute" {
-			if attribute.Type != nil && attribute.Value != nil {
-				if *attribute.Type == "water_grade" {
-					result.WaterGrade = *attribute.Value
-				}
+		return false
+	}
+
+	delete(bot.pendingPrompts, key)
+
+	// commands cancel the prompt and are processed as usual
+	if message.IsCommand() {
+		return false
+	}
+
+	// role could have changed since the question was asked
+	if !role.allows(prompt.required) {
+		bot.Send(message.Chat.ID, "⛔ You need to be "+localizeUserRole(prompt.required)+" to do that")
+		return true
+	}
+
+	err := prompt.handler(message.Chat.ID, strings.TrimSpace(message.Text))
+	if err != nil {
+		log.Println(err)
+		bot.Send(message.Chat.ID, "❌ Error: "+err.Error())
+	}
 
-				if *attribute.Type == "operation_mode" {
-					result.OperationMode = *attribute.Value
-				}
+	return true
+}
 
-				if *attribute.Type == "fan_speed" {
-					result.FanSpeed = *attribute.Value
-				}
-			}
+func (bot *Bot) isPromptMessage(chatId int64, messageId int) bool {
+	for key, prompt := range bot.pendingPrompts {
+		if key.chatId == chatId && prompt.messageId == messageId {
+			return true
 		}
 	}
 
-	return &result
+	return false
 }
 
 func (bot *Bot) Send(receiverId int64, message string) error {
@@ -116,6 +292,21 @@ func (bot *Bot) handleOneTimeCallback(query *tgbotapi.CallbackQuery, args []stri
 	}
 
 	// update message and clear keyboard
+	if query.Message.Photo != nil {
+		_, err = bot.telegramApi.Request(
+			tgbotapi.EditMessageCaptionConfig{
+				BaseEdit: tgbotapi.BaseEdit{
+					ChatID:      query.Message.Chat.ID,
+					MessageID:   query.Message.MessageID,
+					ReplyMarkup: nil,
+				},
+				Caption: response,
+			},
+		)
+
+		return err
+	}
+
 	_, err = bot.telegramApi.Request(
 		tgbotapi.EditMessageTextConfig{
 			BaseEdit: tgbotapi.BaseEdit{
@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SkaceKamen/valetudo-telegram-bot/pkg/valetudo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const zonesStorageKey = "zones"
+
+type savedZone struct {
+	Name       string `json:"name"`
+	X1         int    `json:"x1"`
+	Y1         int    `json:"y1"`
+	X2         int    `json:"x2"`
+	Y2         int    `json:"y2"`
+	Iterations int    `json:"iterations"`
+}
+
+func (zone *savedZone) toValetudoZone() valetudo.ZoneCleaningZone {
+	return valetudo.NewRectangleZone(zone.X1, zone.Y1, zone.X2, zone.Y2)
+}
+
+func (bot *Bot) getZones() ([]savedZone, error) {
+	zones := []savedZone{}
+
+	_, err := bot.storage.Get(zonesStorageKey, &zones)
+	if err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
+
+func (bot *Bot) findZone(name string) (*savedZone, error) {
+	zones, err := bot.getZones()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		if strings.EqualFold(zone.Name, name) {
+			return &zone, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (bot *Bot) handleZoneCommand(requesterId int64, args string) error {
+	if !bot.HasCapability("ZoneCleaningCapability") {
+		return fmt.Errorf("robot doesn't support zone cleaning")
+	}
+
+	args = strings.TrimSpace(args)
+	subcommand, rest, _ := strings.Cut(args, " ")
+
+	switch subcommand {
+	case "":
+		return bot.sendZoneKeyboard(requesterId)
+	case "add":
+		return bot.handleZoneAdd(requesterId, rest)
+	case "remove", "delete":
+		return bot.handleZoneRemove(requesterId, strings.TrimSpace(rest))
+	}
+
+	names := strings.Split(args, ",")
+	zones := []savedZone{}
+
+	for _, name := range names {
+		zone, err := bot.findZone(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+
+		if zone == nil {
+			bot.Send(requesterId, "❌ Zone "+name+" not found")
+			return nil
+		}
+
+		zones = append(zones, *zone)
+	}
+
+	err := bot.cleanZones(zones)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, "🧹 Cleaning "+strings.Join(names, ", "))
+
+	return nil
+}
+
+func (bot *Bot) handleZoneAdd(requesterId int64, args string) error {
+	usage := "Usage: /zone add <name> <x1> <y1> <x2> <y2> [iterations]\nCoordinates are in cm, same as in Valetudo map."
+
+	tokens := strings.Fields(args)
+	numbers := []int{}
+
+	// coordinates (and optional iterations) are trailing numbers, everything before them is the name
+	nameEnd := len(tokens)
+	for nameEnd > 0 && len(numbers) < 5 {
+		number, err := strconv.Atoi(tokens[nameEnd-1])
+		if err != nil {
+			break
+		}
+
+		numbers = append([]int{number}, numbers...)
+		nameEnd--
+	}
+
+	if len(numbers) < 4 || nameEnd == 0 {
+		bot.Send(requesterId, usage)
+		return nil
+	}
+
+	name := strings.Join(tokens[:nameEnd], " ")
+	if len(name) > maxSavedNameLength || strings.Contains(name, ",") {
+		bot.Send(requesterId, fmt.Sprintf("❌ Zone name can't contain commas and has to be at most %d bytes long", maxSavedNameLength))
+		return nil
+	}
+
+	zone := savedZone{
+		Name:       name,
+		X1:         numbers[0],
+		Y1:         numbers[1],
+		X2:         numbers[2],
+		Y2:         numbers[3],
+		Iterations: 1,
+	}
+
+	if len(numbers) == 5 {
+		zone.Iterations = numbers[4]
+	}
+
+	if zone.X1 == zone.X2 || zone.Y1 == zone.Y2 {
+		bot.Send(requesterId, "❌ Zone has to have non-zero width and height")
+		return nil
+	}
+
+	properties, err := bot.robotApi.GetZoneCleaningCapabilityProperties()
+	if err != nil {
+		return err
+	}
+
+	if zone.Iterations < properties.Iterations.Min || zone.Iterations > properties.Iterations.Max {
+		bot.Send(requesterId, fmt.Sprintf("❌ Iterations have to be between %d and %d", properties.Iterations.Min, properties.Iterations.Max))
+		return nil
+	}
+
+	zones, err := bot.getZones()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range zones {
+		if strings.EqualFold(zones[i].Name, zone.Name) {
+			zones[i] = zone
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		zones = append(zones, zone)
+	}
+
+	err = bot.storage.Set(zonesStorageKey, zones)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, "✅ Zone "+zone.Name+" saved")
+
+	return nil
+}
+
+func (bot *Bot) handleZoneRemove(requesterId int64, name string) error {
+	zones, err := bot.getZones()
+	if err != nil {
+		return err
+	}
+
+	result := []savedZone{}
+	for _, zone := range zones {
+		if !strings.EqualFold(zone.Name, name) {
+			result = append(result, zone)
+		}
+	}
+
+	if len(result) == len(zones) {
+		bot.Send(requesterId, "❌ Zone "+name+" not found")
+		return nil
+	}
+
+	err = bot.storage.Set(zonesStorageKey, result)
+	if err != nil {
+		return err
+	}
+
+	bot.Send(requesterId, "🗑 Zone "+name+" removed")
+
+	return nil
+}
+
+func (bot *Bot) cleanZones(zones []savedZone) error {
+	properties, err := bot.robotApi.GetZoneCleaningCapabilityProperties()
+	if err != nil {
+		return err
+	}
+
+	if len(zones) > properties.ZoneCount.Max {
+		return fmt.Errorf("robot can only clean %d zones at once", properties.ZoneCount.Max)
+	}
+
+	// valetudo only supports single iterations value per request, use the highest requested one
+	iterations := properties.Iterations.Min
+	robotZones := []valetudo.ZoneCleaningZone{}
+
+	for _, zone := range zones {
+		iterations = max(iterations, zone.Iterations)
+		robotZones = append(robotZones, zone.toValetudoZone())
+	}
+
+	iterations = min(iterations, properties.Iterations.Max)
+
+	err = bot.robotApi.CleanZones(robotZones, iterations)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, zone := range zones {
+		names = append(names, zone.Name)
+	}
+
+	bot.setSessionTarget("Zone " + strings.Join(names, ", "))
+
+	return nil
+}
+
+func (bot *Bot) sendZoneKeyboard(requesterId int64) error {
+	zones, err := bot.getZones()
+	if err != nil {
+		return err
+	}
+
+	if len(zones) == 0 {
+		bot.Send(requesterId, "📐 No zones saved yet. Add one using /zone add <name> <x1> <y1> <x2> <y2> [iterations]")
+		return nil
+	}
+
+	keyboard := [][]tgbotapi.InlineKeyboardButton{}
+	for _, zone := range zones {
+		label := zone.Name
+		if zone.Iterations > 1 {
+			label += fmt.Sprintf(" (%dx)", zone.Iterations)
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "zone "+zone.Name),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(requesterId, "📐 Which zone do you want to clean?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+
+	_, err = bot.telegramApi.Send(msg)
+
+	return err
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage is a key/value store for bot data, values are serialized as JSON
+type Storage interface {
+	// Get loads value stored under key into target, returns false when the key doesn't exist
+	Get(key string, into any) (bool, error)
+	Set(key string, value any) error
+	Delete(key string) error
+}
+
+// Open creates storage of specified type, "file" uses path as the data file, "memory" keeps data until restart
+func Open(storageType string, path string) (Storage, error) {
+	switch storageType {
+	case "", "file":
+		fileStorage, err := OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return fileStorage, nil
+	case "memory":
+		return NewMemoryStorage(), nil
+	}
+
+	return nil, fmt.Errorf("unknown storage type %s", storageType)
+}
+
+// FileStorage is a simple key/value store persisted as a single JSON file
+type FileStorage struct {
+	path  string
+	mutex sync.Mutex
+	data  map[string]json.RawMessage
+}
+
+func OpenFile(path string) (*FileStorage, error) {
+	storage := &FileStorage{path: path, data: map[string]json.RawMessage{}}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return storage, nil
+		}
+
+		return nil, fmt.Errorf("failed to read storage file: %w", err)
+	}
+
+	if len(content) == 0 {
+		return storage, nil
+	}
+
+	err = json.Unmarshal(content, &storage.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse storage file: %w", err)
+	}
+
+	return storage, nil
+}
+
+func (storage *FileStorage) Get(key string, into any) (bool, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	value, ok := storage.data[key]
+	if !ok {
+		return false, nil
+	}
+
+	err := json.Unmarshal(value, into)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse stored value %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (storage *FileStorage) Set(key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value %s: %w", key, err)
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.data[key] = encoded
+
+	return storage.flush()
+}
+
+func (storage *FileStorage) Delete(key string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, ok := storage.data[key]; !ok {
+		return nil
+	}
+
+	delete(storage.data, key)
+
+	return storage.flush()
+}
+
+// flush writes the whole store to disk, expects the mutex to be held
+func (storage *FileStorage) flush() error {
+	content, err := json.MarshalIndent(storage.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(storage.path)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// write into temporary file first so we don't end up with half written file on crash
+	tmpPath := storage.path + ".tmp"
+	err = os.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, storage.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace storage file: %w", err)
+	}
+
+	return nil
+}
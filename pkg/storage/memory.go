@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MemoryStorage keeps everything in memory, useful when persistence isn't wanted
+type MemoryStorage struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: map[string][]byte{}}
+}
+
+func (storage *MemoryStorage) Get(key string, into any) (bool, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	value, ok := storage.data[key]
+	if !ok {
+		return false, nil
+	}
+
+	// values are kept encoded so callers can't modify stored data through shared references
+	err := json.Unmarshal(value, into)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse stored value %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (storage *MemoryStorage) Set(key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value %s: %w", key, err)
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.data[key] = encoded
+
+	return nil
+}
+
+func (storage *MemoryStorage) Delete(key string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	delete(storage.data, key)
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package storage
+
+// PrefixedStorage keeps its keys under a prefix in another storage, so several owners can share one data file
+type PrefixedStorage struct {
+	storage Storage
+	prefix  string
+}
+
+func WithPrefix(storage Storage, prefix string) *PrefixedStorage {
+	return &PrefixedStorage{storage: storage, prefix: prefix}
+}
+
+func (storage *PrefixedStorage) Get(key string, into any) (bool, error) {
+	return storage.storage.Get(storage.prefix+key, into)
+}
+
+func (storage *PrefixedStorage) Set(key string, value any) error {
+	return storage.storage.Set(storage.prefix+key, value)
+}
+
+func (storage *PrefixedStorage) Delete(key string) error {
+	return storage.storage.Delete(storage.prefix + key)
+}
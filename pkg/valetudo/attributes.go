@@ -0,0 +1,233 @@
+package valetudo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	BatteryStateAttributeClass         = "BatteryStateAttribute"
+	StatusStateAttributeClass          = "StatusStateAttribute"
+	AttachmentStateAttributeClass      = "AttachmentStateAttribute"
+	PresetSelectionStateAttributeClass = "PresetSelectionStateAttribute"
+	DockStatusStateAttributeClass      = "DockStatusStateAttribute"
+	ConsumableStateAttributeClass      = "ConsumableStateAttribute"
+)
+
+// types of PresetSelectionStateAttribute
+const (
+	PresetFanSpeed      = "fan_speed"
+	PresetWaterGrade    = "water_grade"
+	PresetOperationMode = "operation_mode"
+)
+
+// RobotStateAttribute is one of the *StateAttribute types below, use type switch or accessors of RobotStateAttributes
+type RobotStateAttribute interface {
+	AttributeClass() string
+}
+
+// StateAttributeBase is embedded in every attribute so they are encoded back with their class
+type StateAttributeBase struct {
+	Class string `json:"__class"`
+}
+
+func (base StateAttributeBase) AttributeClass() string {
+	return base.Class
+}
+
+type BatteryStateAttribute struct {
+	StateAttributeBase
+	Level int `json:"level"`
+	// none, charging, discharging or charged
+	Flag string `json:"flag"`
+}
+
+type StatusStateAttribute struct {
+	StateAttributeBase
+	Value string `json:"value"`
+	// additional info such as resumable, segment, zone or spot
+	Flag  string              `json:"flag"`
+	Error *ValetudoRobotError `json:"error,omitempty"`
+}
+
+type AttachmentStateAttribute struct {
+	StateAttributeBase
+	// dustbin, watertank or mop
+	Type     string `json:"type"`
+	Attached bool   `json:"attached"`
+}
+
+type PresetSelectionStateAttribute struct {
+	StateAttributeBase
+	// one of Preset* constants
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// set when Value is custom
+	CustomValue *int `json:"customValue,omitempty"`
+}
+
+type DockStatusStateAttribute struct {
+	StateAttributeBase
+	// error, idle, pause, emptying, cleaning or drying
+	Value string `json:"value"`
+}
+
+// ConsumableStateAttribute is also returned by ConsumableMonitoringCapability
+type ConsumableStateAttribute struct {
+	StateAttributeBase
+	Type      string                       `json:"type"`
+	SubType   string                       `json:"subType"`
+	Remaining RobotStateRemainingAttribute `json:"remaining"`
+}
+
+// UnknownStateAttribute keeps attributes of classes this package doesn't know yet
+type UnknownStateAttribute struct {
+	StateAttributeBase
+	Raw json.RawMessage `json:"-"`
+}
+
+func (attribute UnknownStateAttribute) MarshalJSON() ([]byte, error) {
+	return attribute.Raw, nil
+}
+
+// RobotStateAttributes decodes every attribute into its own type based on the __class field
+type RobotStateAttributes []RobotStateAttribute
+
+func (attributes *RobotStateAttributes) UnmarshalJSON(data []byte) error {
+	items := []json.RawMessage{}
+
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return err
+	}
+
+	result := make(RobotStateAttributes, 0, len(items))
+
+	for _, item := range items {
+		attribute, err := ParseRobotStateAttribute(item)
+		if err != nil {
+			return err
+		}
+
+		result = append(result, attribute)
+	}
+
+	*attributes = result
+
+	return nil
+}
+
+func ParseRobotStateAttribute(data []byte) (RobotStateAttribute, error) {
+	base := StateAttributeBase{}
+
+	err := json.Unmarshal(data, &base)
+	if err != nil {
+		return nil, err
+	}
+
+	var attribute RobotStateAttribute
+
+	switch base.Class {
+	case BatteryStateAttributeClass:
+		attribute = &BatteryStateAttribute{}
+	case StatusStateAttributeClass:
+		attribute = &StatusStateAttribute{}
+	case AttachmentStateAttributeClass:
+		attribute = &AttachmentStateAttribute{}
+	case PresetSelectionStateAttributeClass:
+		attribute = &PresetSelectionStateAttribute{}
+	case DockStatusStateAttributeClass:
+		attribute = &DockStatusStateAttribute{}
+	case ConsumableStateAttributeClass:
+		attribute = &ConsumableStateAttribute{}
+	default:
+		return &UnknownStateAttribute{StateAttributeBase: base, Raw: append(json.RawMessage{}, data...)}, nil
+	}
+
+	err = json.Unmarshal(data, attribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", base.Class, err)
+	}
+
+	return attribute, nil
+}
+
+// Battery returns nil when the robot doesn't report battery
+func (attributes RobotStateAttributes) Battery() *BatteryStateAttribute {
+	for _, attribute := range attributes {
+		if battery, ok := attribute.(*BatteryStateAttribute); ok {
+			return battery
+		}
+	}
+
+	return nil
+}
+
+func (attributes RobotStateAttributes) Status() *StatusStateAttribute {
+	for _, attribute := range attributes {
+		if status, ok := attribute.(*StatusStateAttribute); ok {
+			return status
+		}
+	}
+
+	return nil
+}
+
+// DockStatus returns nil for robots without an auto-empty or mop washing dock
+func (attributes RobotStateAttributes) DockStatus() *DockStatusStateAttribute {
+	for _, attribute := range attributes {
+		if dock, ok := attribute.(*DockStatusStateAttribute); ok {
+			return dock
+		}
+	}
+
+	return nil
+}
+
+// Preset returns selected preset of the specified type, see Preset* constants
+func (attributes RobotStateAttributes) Preset(presetType string) *PresetSelectionStateAttribute {
+	for _, attribute := range attributes {
+		if preset, ok := attribute.(*PresetSelectionStateAttribute); ok && preset.Type == presetType {
+			return preset
+		}
+	}
+
+	return nil
+}
+
+func (attributes RobotStateAttributes) Attachments() []AttachmentStateAttribute {
+	result := []AttachmentStateAttribute{}
+
+	for _, attribute := range attributes {
+		if attachment, ok := attribute.(*AttachmentStateAttribute); ok {
+			result = append(result, *attachment)
+		}
+	}
+
+	return result
+}
+
+func (attributes RobotStateAttributes) Consumables() []ConsumableStateAttribute {
+	result := []ConsumableStateAttribute{}
+
+	for _, attribute := range attributes {
+		if consumable, ok := attribute.(*ConsumableStateAttribute); ok {
+			result = append(result, *consumable)
+		}
+	}
+
+	return result
+}
+
+// Unknown returns attributes that weren't decoded into any known type
+func (attributes RobotStateAttributes) Unknown() []UnknownStateAttribute {
+	result := []UnknownStateAttribute{}
+
+	for _, attribute := range attributes {
+		if unknown, ok := attribute.(*UnknownStateAttribute); ok {
+			result = append(result, *unknown)
+		}
+	}
+
+	return result
+}
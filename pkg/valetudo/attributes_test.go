@@ -0,0 +1,205 @@
+package valetudo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestParseRobotStateAttribute(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected RobotStateAttribute
+	}{
+		{
+			name: "battery",
+			data: `{"__class":"BatteryStateAttribute","level":87,"flag":"charging"}`,
+			expected: &BatteryStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: BatteryStateAttributeClass},
+				Level:              87,
+				Flag:               "charging",
+			},
+		},
+		{
+			name: "status",
+			data: `{"__class":"StatusStateAttribute","value":"cleaning","flag":"segment"}`,
+			expected: &StatusStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: StatusStateAttributeClass},
+				Value:              "cleaning",
+				Flag:               "segment",
+			},
+		},
+		{
+			name: "status with error",
+			data: `{"__class":"StatusStateAttribute","value":"error","flag":"none","error":{"severity":{"kind":"transient","level":"error"},"subsystem":"motors","message":"Main brush jammed"}}`,
+			expected: &StatusStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: StatusStateAttributeClass},
+				Value:              "error",
+				Flag:               "none",
+				Error: &ValetudoRobotError{
+					Severity:  ValetudoRobotErrorSeverity{Kind: "transient", Level: "error"},
+					Subsystem: "motors",
+					Message:   "Main brush jammed",
+				},
+			},
+		},
+		{
+			name: "attachment",
+			data: `{"__class":"AttachmentStateAttribute","type":"mop","attached":true}`,
+			expected: &AttachmentStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: AttachmentStateAttributeClass},
+				Type:               "mop",
+				Attached:           true,
+			},
+		},
+		{
+			name: "preset",
+			data: `{"__class":"PresetSelectionStateAttribute","type":"fan_speed","value":"max"}`,
+			expected: &PresetSelectionStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: PresetSelectionStateAttributeClass},
+				Type:               PresetFanSpeed,
+				Value:              "max",
+			},
+		},
+		{
+			name: "preset with custom value",
+			data: `{"__class":"PresetSelectionStateAttribute","type":"water_grade","value":"custom","customValue":42}`,
+			expected: &PresetSelectionStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: PresetSelectionStateAttributeClass},
+				Type:               PresetWaterGrade,
+				Value:              "custom",
+				CustomValue:        intPointer(42),
+			},
+		},
+		{
+			name: "dock status",
+			data: `{"__class":"DockStatusStateAttribute","value":"emptying"}`,
+			expected: &DockStatusStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: DockStatusStateAttributeClass},
+				Value:              "emptying",
+			},
+		},
+		{
+			name: "consumable",
+			data: `{"__class":"ConsumableStateAttribute","type":"brush","subType":"main","remaining":{"value":1200,"unit":"minutes"}}`,
+			expected: &ConsumableStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: ConsumableStateAttributeClass},
+				Type:               "brush",
+				SubType:            "main",
+				Remaining:          RobotStateRemainingAttribute{Value: intPointer(1200), Unit: stringPointer("minutes")},
+			},
+		},
+		{
+			name: "unknown class",
+			data: `{"__class":"FutureStateAttribute","foo":[1,2]}`,
+			expected: &UnknownStateAttribute{
+				StateAttributeBase: StateAttributeBase{Class: "FutureStateAttribute"},
+				Raw:                json.RawMessage(`{"__class":"FutureStateAttribute","foo":[1,2]}`),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attribute, err := ParseRobotStateAttribute([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(attribute, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, attribute)
+			}
+		})
+	}
+}
+
+func TestParseRobotStateAttributeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not an object", data: `[1]`},
+		{name: "wrong field type", data: `{"__class":"BatteryStateAttribute","level":"full"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseRobotStateAttribute([]byte(test.data))
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestRobotStateAttributesAccessors(t *testing.T) {
+	data := `[
+		{"__class":"BatteryStateAttribute","level":50,"flag":"discharging"},
+		{"__class":"StatusStateAttribute","value":"docked","flag":"none"},
+		{"__class":"PresetSelectionStateAttribute","type":"fan_speed","value":"low"},
+		{"__class":"PresetSelectionStateAttribute","type":"operation_mode","value":"vacuum"},
+		{"__class":"AttachmentStateAttribute","type":"dustbin","attached":true},
+		{"__class":"AttachmentStateAttribute","type":"watertank","attached":false},
+		{"__class":"ConsumableStateAttribute","type":"filter","subType":"main","remaining":{"value":90,"unit":"percent"}},
+		{"__class":"SomethingNewAttribute","value":1}
+	]`
+
+	attributes := RobotStateAttributes{}
+
+	err := json.Unmarshal([]byte(data), &attributes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if battery := attributes.Battery(); battery == nil || battery.Level != 50 {
+		t.Errorf("unexpected battery %#v", battery)
+	}
+
+	if status := attributes.Status(); status == nil || status.Value != "docked" {
+		t.Errorf("unexpected status %#v", status)
+	}
+
+	if dock := attributes.DockStatus(); dock != nil {
+		t.Errorf("expected no dock status, got %#v", dock)
+	}
+
+	if preset := attributes.Preset(PresetOperationMode); preset == nil || preset.Value != "vacuum" {
+		t.Errorf("unexpected operation mode %#v", preset)
+	}
+
+	if preset := attributes.Preset(PresetWaterGrade); preset != nil {
+		t.Errorf("expected no water grade, got %#v", preset)
+	}
+
+	if count := len(attributes.Attachments()); count != 2 {
+		t.Errorf("expected 2 attachments, got %d", count)
+	}
+
+	if count := len(attributes.Consumables()); count != 1 {
+		t.Errorf("expected 1 consumable, got %d", count)
+	}
+
+	unknown := attributes.Unknown()
+	if len(unknown) != 1 || unknown[0].Class != "SomethingNewAttribute" {
+		t.Fatalf("unexpected unknown attributes %#v", unknown)
+	}
+
+	// unknown attributes are encoded back as they were received
+	encoded, err := json.Marshal(unknown[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(encoded) != `{"__class":"SomethingNewAttribute","value":1}` {
+		t.Errorf("unexpected encoded attribute %s", encoded)
+	}
+}
@@ -1,22 +1,28 @@
 package valetudo
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"sort"
 
 	"github.com/r3labs/sse/v2"
 )
 
 type ValetudoClient struct {
 	Url string
+
+	options    ClientOptions
+	httpClient *http.Client
 }
 
+// Init creates client without authentication, see InitWithOptions
 func Init(url string) ValetudoClient {
 	return ValetudoClient{Url: url}
 }
 
 func (client *ValetudoClient) GetRobotState() (*RobotState, error) {
-	res, err := http.Get(client.Url + "/api/v2/robot/state")
+	res, err := client.get("/api/v2/robot/state")
 
 	if err != nil {
 		return nil, err
@@ -33,8 +39,8 @@ func (client *ValetudoClient) GetRobotState() (*RobotState, error) {
 	return ParseRobotState(body)
 }
 
-func (client *ValetudoClient) GetRobotStateAttributes() (*[]RobotStateAttribute, error) {
-	res, err := http.Get(client.Url + "/api/v2/robot/state/attributes")
+func (client *ValetudoClient) GetRobotStateAttributes() (*RobotStateAttributes, error) {
+	res, err := client.get("/api/v2/robot/state/attributes")
 
 	if err != nil {
 		return nil, err
@@ -83,10 +89,12 @@ func (client *ValetudoClient) Stop() error {
 	return err
 }
 
-func (client *ValetudoClient) CleanMapSegments(segmentIds []string, iterations int) error {
+func (client *ValetudoClient) CleanMapSegments(segmentIds []string, iterations int, customOrder bool) error {
 	request := MapSegmentationCapabilityPutRequest{
-		Action:     "start_segment_action",
-		SegmentIds: segmentIds,
+		Action:      "start_segment_action",
+		SegmentIds:  segmentIds,
+		Iterations:  &iterations,
+		CustomOrder: &customOrder,
 	}
 
 	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/MapSegmentationCapability", request)
@@ -94,8 +102,19 @@ func (client *ValetudoClient) CleanMapSegments(segmentIds []string, iterations i
 	return err
 }
 
+func (client *ValetudoClient) GetMapSegmentationCapabilityProperties() (*MapSegmentationCapabilityProperties, error) {
+	result := MapSegmentationCapabilityProperties{}
+	err := client.GetRequest("/api/v2/robot/capabilities/MapSegmentationCapability/properties", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 func (client *ValetudoClient) ListenToStateChanges(callback func(*RobotState, error)) error {
-	sseClient := sse.NewClient(client.Url + "/api/v2/robot/state/sse")
+	sseClient := client.newSseClient("/api/v2/robot/state/sse")
 	sseClient.Subscribe("messages", func(msg *sse.Event) {
 		state, err := ParseRobotState(msg.Data)
 
@@ -110,8 +129,8 @@ func (client *ValetudoClient) ListenToStateChanges(callback func(*RobotState, er
 	return nil
 }
 
-func (client *ValetudoClient) ListenToStateAttributesChanges(callback func(*[]RobotStateAttribute, error)) error {
-	sseClient := sse.NewClient(client.Url + "/api/v2/robot/state/attributes/sse")
+func (client *ValetudoClient) ListenToStateAttributesChanges(callback func(*RobotStateAttributes, error)) error {
+	sseClient := client.newSseClient("/api/v2/robot/state/attributes/sse")
 	sseClient.Subscribe("messages", func(msg *sse.Event) {
 		state, err := ParseRobotStateAttributes(msg.Data)
 
@@ -126,6 +145,27 @@ func (client *ValetudoClient) ListenToStateAttributesChanges(callback func(*[]Ro
 	return nil
 }
 
+// ListenToMapChanges calls callback with every map update until the context is cancelled
+func (client *ValetudoClient) ListenToMapChanges(ctx context.Context, callback func(*RobotStateMap, error)) error {
+	sseClient := client.newSseClient("/api/v2/robot/state/map/sse")
+
+	return sseClient.SubscribeWithContext(ctx, "messages", func(msg *sse.Event) {
+		// keep-alive events don't carry any data
+		if len(msg.Data) == 0 {
+			return
+		}
+
+		robotMap, err := ParseRobotStateMap(msg.Data)
+
+		if err != nil {
+			callback(nil, err)
+			return
+		}
+
+		callback(robotMap, nil)
+	})
+}
+
 func (client *ValetudoClient) GetRobotCapabilities() (*[]string, error) {
 	result := []string{}
 	err := client.GetRequest("/api/v2/robot/capabilities", &result)
@@ -160,3 +200,252 @@ func (client *ValetudoClient) GetOperationModeControlCapabilityPresets() (*[]str
 func (client *ValetudoClient) SetOperationModeControlCapabilityPreset(preset string) error {
 	return client.setRobotCapabilityPreset("OperationModeControlCapability", preset)
 }
+
+func (client *ValetudoClient) GetZoneCleaningCapabilityProperties() (*ZoneCleaningCapabilityProperties, error) {
+	result := ZoneCleaningCapabilityProperties{}
+	err := client.GetRequest("/api/v2/robot/capabilities/ZoneCleaningCapability/properties", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (client *ValetudoClient) CleanZones(zones []ZoneCleaningZone, iterations int) error {
+	request := ZoneCleaningCapabilityPutRequest{
+		Action:     "clean",
+		Zones:      zones,
+		Iterations: &iterations,
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/ZoneCleaningCapability", request)
+
+	return err
+}
+
+func (client *ValetudoClient) GoToLocation(x int, y int) error {
+	request := GoToLocationCapabilityPutRequest{
+		Action:      "goto",
+		Coordinates: MapPoint{X: x, Y: y},
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/GoToLocationCapability", request)
+
+	return err
+}
+
+func (client *ValetudoClient) GetConsumables() (*[]ConsumableStateAttribute, error) {
+	result := []ConsumableStateAttribute{}
+	err := client.GetRequest("/api/v2/robot/capabilities/ConsumableMonitoringCapability", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (client *ValetudoClient) ResetConsumable(consumableType string, subType string) error {
+	url := "/api/v2/robot/capabilities/ConsumableMonitoringCapability/" + consumableType
+	if subType != "" {
+		url += "/" + subType
+	}
+
+	err := client.PushRequest("PUT", url, ConsumableResetRequest{
+		Action: "reset",
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) RenameMapSegment(segmentId string, name string) error {
+	request := MapSegmentRenameCapabilityPutRequest{
+		Action:    "rename_segment",
+		SegmentId: segmentId,
+		Name:      name,
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/MapSegmentRenameCapability", request)
+
+	return err
+}
+
+func (client *ValetudoClient) JoinMapSegments(segmentAId string, segmentBId string) error {
+	request := MapSegmentEditJoinRequest{
+		Action:     "join_segments",
+		SegmentAId: segmentAId,
+		SegmentBId: segmentBId,
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/MapSegmentEditCapability", request)
+
+	return err
+}
+
+// SplitMapSegment splits segment along line defined by two points, coordinates are in cm
+func (client *ValetudoClient) SplitMapSegment(segmentId string, pA MapPoint, pB MapPoint) error {
+	request := MapSegmentEditSplitRequest{
+		Action:    "split_segment",
+		SegmentId: segmentId,
+		PA:        pA,
+		PB:        pB,
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/MapSegmentEditCapability", request)
+
+	return err
+}
+
+func (client *ValetudoClient) GetCombinedVirtualRestrictionsCapabilityProperties() (*CombinedVirtualRestrictionsCapabilityProperties, error) {
+	result := CombinedVirtualRestrictionsCapabilityProperties{}
+	err := client.GetRequest("/api/v2/robot/capabilities/CombinedVirtualRestrictionsCapability/properties", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SetVirtualRestrictions replaces all virtual walls and restricted zones with the provided ones
+func (client *ValetudoClient) SetVirtualRestrictions(restrictions CombinedVirtualRestrictions) error {
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/CombinedVirtualRestrictionsCapability", restrictions)
+
+	return err
+}
+
+func (client *ValetudoClient) Locate() error {
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/LocateCapability", BasicControlCapabilityRequest{
+		Action: "locate",
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) SetManualControlEnabled(enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/ManualControlCapability", ManualControlCapabilityPutRequest{
+		Action: action,
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) ManualControlMove(movementCommand string) error {
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/ManualControlCapability", ManualControlCapabilityPutRequest{
+		Action:          "move",
+		MovementCommand: movementCommand,
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) SetHighResolutionManualControlEnabled(enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/HighResolutionManualControlCapability", HighResolutionManualControlCapabilityPutRequest{
+		Action: action,
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) HighResolutionManualControlMove(velocity float64, angle float64) error {
+	err := client.PushRequest("PUT", "/api/v2/robot/capabilities/HighResolutionManualControlCapability", HighResolutionManualControlCapabilityPutRequest{
+		Action: "move",
+		Vector: &HighResolutionManualControlVector{
+			Velocity: velocity,
+			Angle:    angle,
+		},
+	})
+
+	return err
+}
+
+func (client *ValetudoClient) GetTimers() (*[]ValetudoTimer, error) {
+	timers := map[string]ValetudoTimer{}
+	err := client.GetRequest("/api/v2/timers", &timers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ValetudoTimer{}
+	for _, timer := range timers {
+		result = append(result, timer)
+	}
+
+	return &result, nil
+}
+
+func (client *ValetudoClient) GetTimersProperties() (*ValetudoTimersProperties, error) {
+	result := ValetudoTimersProperties{}
+	err := client.GetRequest("/api/v2/timers/properties", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (client *ValetudoClient) CreateTimer(timer ValetudoTimer) error {
+	timer.Id = ""
+
+	return client.PushRequest("POST", "/api/v2/timers", timer)
+}
+
+func (client *ValetudoClient) UpdateTimer(timer ValetudoTimer) error {
+	return client.PushRequest("PUT", "/api/v2/timers/"+timer.Id, timer)
+}
+
+func (client *ValetudoClient) DeleteTimer(id string) error {
+	return client.PushRequest("DELETE", "/api/v2/timers/"+id, nil)
+}
+
+// GetCurrentStatistics returns statistics of the current or last cleanup
+func (client *ValetudoClient) GetCurrentStatistics() (*[]ValetudoDataPoint, error) {
+	result := []ValetudoDataPoint{}
+	err := client.GetRequest("/api/v2/robot/capabilities/CurrentStatisticsCapability", &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetEvents returns events sorted from the oldest one
+func (client *ValetudoClient) GetEvents() (*[]ValetudoEvent, error) {
+	events := map[string]ValetudoEvent{}
+	err := client.GetRequest("/api/v2/events", &events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ValetudoEvent{}
+	for _, event := range events {
+		result = append(result, event)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+
+	return &result, nil
+}
+
+func (client *ValetudoClient) InteractWithEvent(id string, interaction string) error {
+	return client.PushRequest("PUT", "/api/v2/events/"+id+"/interact", ValetudoEventInteractionRequest{
+		Interaction: interaction,
+	})
+}
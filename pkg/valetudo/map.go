@@ -0,0 +1,180 @@
+package valetudo
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// RobotStateMapVersion is the newest map format this package understands, older maps are migrated when parsed
+const RobotStateMapVersion = 2
+
+const RobotStateMapClass = "ValetudoMap"
+
+const (
+	PointMapEntityClass   = "PointMapEntity"
+	PathMapEntityClass    = "PathMapEntity"
+	PolygonMapEntityClass = "PolygonMapEntity"
+	LineMapEntityClass    = "LineMapEntity"
+)
+
+const (
+	// points
+	MapEntityRobotPosition   = "robot_position"
+	MapEntityChargerLocation = "charger_location"
+	MapEntityGoToTarget      = "go_to_target"
+	MapEntityObstacle        = "obstacle"
+	// paths
+	MapEntityPath          = "path"
+	MapEntityPredictedPath = "predicted_path"
+	// polygons
+	MapEntityNoGoArea   = "no_go_area"
+	MapEntityNoMopArea  = "no_mop_area"
+	MapEntityActiveZone = "active_zone"
+	// lines
+	MapEntityVirtualWall = "virtual_wall"
+)
+
+const MapLayerClass = "MapLayer"
+
+const (
+	MapLayerFloor   = "floor"
+	MapLayerWall    = "wall"
+	MapLayerSegment = "segment"
+)
+
+const (
+	MapLayerMaterialGeneric        = "generic"
+	MapLayerMaterialTile           = "tile"
+	MapLayerMaterialWood           = "wood"
+	MapLayerMaterialWoodHorizontal = "wood_horizontal"
+	MapLayerMaterialWoodVertical   = "wood_vertical"
+)
+
+// class of entities in maps that didn't include it
+var mapEntityClasses = map[string]string{
+	MapEntityRobotPosition:   PointMapEntityClass,
+	MapEntityChargerLocation: PointMapEntityClass,
+	MapEntityGoToTarget:      PointMapEntityClass,
+	MapEntityObstacle:        PointMapEntityClass,
+	MapEntityPath:            PathMapEntityClass,
+	MapEntityPredictedPath:   PathMapEntityClass,
+	MapEntityNoGoArea:        PolygonMapEntityClass,
+	MapEntityNoMopArea:       PolygonMapEntityClass,
+	MapEntityActiveZone:      PolygonMapEntityClass,
+	MapEntityVirtualWall:     LineMapEntityClass,
+}
+
+// ParseRobotStateMap parses map received from the robot or exported from Valetudo UI, old formats are migrated
+func ParseRobotStateMap(body []byte) (*RobotStateMap, error) {
+	var robotMap RobotStateMap
+
+	err := json.Unmarshal(body, &robotMap)
+	if err != nil {
+		return nil, err
+	}
+
+	MigrateRobotStateMap(&robotMap)
+
+	return &robotMap, nil
+}
+
+// MigrateRobotStateMap upgrades map to RobotStateMapVersion in place, maps without version are treated as version 1,
+// newer maps are kept as they are and only fields known to this package are used
+func MigrateRobotStateMap(robotMap *RobotStateMap) {
+	if robotMap.Metadata.Version < 1 {
+		robotMap.Metadata.Version = 1
+	}
+
+	if robotMap.Class == "" {
+		robotMap.Class = RobotStateMapClass
+	}
+
+	// version 1 stored every pixel of a layer, version 2 stores runs of pixels
+	if robotMap.Metadata.Version < 2 {
+		for i := range robotMap.Layers {
+			layer := &robotMap.Layers[i]
+
+			if len(layer.CompressedPixels) == 0 && len(layer.Pixels) > 0 {
+				layer.CompressedPixels = compressLayerPixels(layer.Pixels)
+				layer.Pixels = nil
+			}
+		}
+
+		robotMap.Metadata.Version = 2
+	}
+
+	totalLayerArea := 0
+
+	for i := range robotMap.Layers {
+		layer := &robotMap.Layers[i]
+
+		if layer.Class == "" {
+			layer.Class = MapLayerClass
+		}
+
+		if layer.Dimensions.PixelCount == 0 {
+			layer.Dimensions.PixelCount = countLayerPixels(layer)
+		}
+
+		totalLayerArea += layer.Dimensions.PixelCount
+	}
+
+	if robotMap.Metadata.TotalLayerArea == 0 {
+		robotMap.Metadata.TotalLayerArea = totalLayerArea
+	}
+
+	for i := range robotMap.Entities {
+		entity := &robotMap.Entities[i]
+
+		if entity.Class == "" {
+			entity.Class = mapEntityClasses[entity.Type]
+		}
+	}
+}
+
+// compressLayerPixels converts x, y pairs into x, y, count runs of horizontally adjacent pixels
+func compressLayerPixels(pixels []int) []int {
+	type pixel struct{ x, y int }
+
+	sorted := make([]pixel, 0, len(pixels)/2)
+	for i := 0; i+1 < len(pixels); i += 2 {
+		sorted = append(sorted, pixel{pixels[i], pixels[i+1]})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].y != sorted[j].y {
+			return sorted[i].y < sorted[j].y
+		}
+
+		return sorted[i].x < sorted[j].x
+	})
+
+	result := []int{}
+
+	for _, current := range sorted {
+		last := len(result) - 3
+		if last >= 0 && result[last+1] == current.y && result[last]+result[last+2] == current.x {
+			result[last+2]++
+			continue
+		}
+
+		// duplicate pixel
+		if last >= 0 && result[last+1] == current.y && result[last]+result[last+2] > current.x {
+			continue
+		}
+
+		result = append(result, current.x, current.y, 1)
+	}
+
+	return result
+}
+
+func countLayerPixels(layer *RobotStateMapLayer) int {
+	count := len(layer.Pixels) / 2
+
+	for i := 2; i < len(layer.CompressedPixels); i += 3 {
+		count += layer.CompressedPixels[i]
+	}
+
+	return count
+}
@@ -0,0 +1,201 @@
+package valetudo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompressLayerPixels(t *testing.T) {
+	tests := []struct {
+		name     string
+		pixels   []int
+		expected []int
+	}{
+		{
+			name:     "empty",
+			pixels:   []int{},
+			expected: []int{},
+		},
+		{
+			name:     "single pixel",
+			pixels:   []int{5, 7},
+			expected: []int{5, 7, 1},
+		},
+		{
+			name:     "horizontal run",
+			pixels:   []int{1, 1, 2, 1, 3, 1},
+			expected: []int{1, 1, 3},
+		},
+		{
+			name:     "unsorted run",
+			pixels:   []int{3, 1, 1, 1, 2, 1},
+			expected: []int{1, 1, 3},
+		},
+		{
+			name:     "gap splits run",
+			pixels:   []int{1, 1, 2, 1, 4, 1},
+			expected: []int{1, 1, 2, 4, 1, 1},
+		},
+		{
+			name:     "rows are sorted by y",
+			pixels:   []int{1, 2, 1, 1, 2, 2},
+			expected: []int{1, 1, 1, 1, 2, 2},
+		},
+		{
+			name:     "same x in different rows",
+			pixels:   []int{4, 1, 4, 2},
+			expected: []int{4, 1, 1, 4, 2, 1},
+		},
+		{
+			name:     "duplicate pixel",
+			pixels:   []int{1, 1, 1, 1},
+			expected: []int{1, 1, 1},
+		},
+		{
+			name:     "duplicates inside run",
+			pixels:   []int{1, 1, 2, 1, 2, 1, 3, 1, 1, 1},
+			expected: []int{1, 1, 3},
+		},
+		{
+			name:     "trailing odd value is ignored",
+			pixels:   []int{1, 1, 9},
+			expected: []int{1, 1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := compressLayerPixels(test.pixels)
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseRobotStateMapMigration(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		version        int
+		compressed     [][]int
+		pixelCounts    []int
+		totalLayerArea int
+	}{
+		{
+			name:           "version 1 pixels are compressed",
+			data:           `{"layers":[{"type":"floor","pixels":[1,1,2,1,3,1,1,2]},{"type":"wall","pixels":[0,0,0,0]}]}`,
+			version:        2,
+			compressed:     [][]int{{1, 1, 3, 1, 2, 1}, {0, 0, 1}},
+			pixelCounts:    []int{4, 1},
+			totalLayerArea: 5,
+		},
+		{
+			name:           "explicit version 1",
+			data:           `{"metaData":{"version":1},"layers":[{"type":"floor","pixels":[5,5]}]}`,
+			version:        2,
+			compressed:     [][]int{{5, 5, 1}},
+			pixelCounts:    []int{1},
+			totalLayerArea: 1,
+		},
+		{
+			name:           "version 2 counts are backfilled",
+			data:           `{"metaData":{"version":2},"layers":[{"type":"floor","compressedPixels":[0,0,10,0,1,5]}]}`,
+			version:        2,
+			compressed:     [][]int{{0, 0, 10, 0, 1, 5}},
+			pixelCounts:    []int{15},
+			totalLayerArea: 15,
+		},
+		{
+			name:           "reported counts are kept",
+			data:           `{"metaData":{"version":2,"totalLayerArea":100},"layers":[{"type":"floor","dimensions":{"pixelCount":7},"compressedPixels":[0,0,3]}]}`,
+			version:        2,
+			compressed:     [][]int{{0, 0, 3}},
+			pixelCounts:    []int{7},
+			totalLayerArea: 100,
+		},
+		{
+			name:           "newer version is kept",
+			data:           `{"metaData":{"version":3},"layers":[{"type":"floor","compressedPixels":[0,0,2]}]}`,
+			version:        3,
+			compressed:     [][]int{{0, 0, 2}},
+			pixelCounts:    []int{2},
+			totalLayerArea: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			robotMap, err := ParseRobotStateMap([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if robotMap.Metadata.Version != test.version {
+				t.Errorf("expected version %d, got %d", test.version, robotMap.Metadata.Version)
+			}
+
+			if robotMap.Class != RobotStateMapClass {
+				t.Errorf("expected class %s, got %s", RobotStateMapClass, robotMap.Class)
+			}
+
+			if len(robotMap.Layers) != len(test.compressed) {
+				t.Fatalf("expected %d layers, got %d", len(test.compressed), len(robotMap.Layers))
+			}
+
+			for i, layer := range robotMap.Layers {
+				if len(layer.Pixels) != 0 {
+					t.Errorf("layer %d still has uncompressed pixels %v", i, layer.Pixels)
+				}
+
+				if !reflect.DeepEqual(layer.CompressedPixels, test.compressed[i]) {
+					t.Errorf("layer %d: expected pixels %v, got %v", i, test.compressed[i], layer.CompressedPixels)
+				}
+
+				if layer.Dimensions.PixelCount != test.pixelCounts[i] {
+					t.Errorf("layer %d: expected pixel count %d, got %d", i, test.pixelCounts[i], layer.Dimensions.PixelCount)
+				}
+
+				if layer.Class != MapLayerClass {
+					t.Errorf("layer %d: expected class %s, got %s", i, MapLayerClass, layer.Class)
+				}
+			}
+
+			if robotMap.Metadata.TotalLayerArea != test.totalLayerArea {
+				t.Errorf("expected total layer area %d, got %d", test.totalLayerArea, robotMap.Metadata.TotalLayerArea)
+			}
+		})
+	}
+}
+
+func TestParseRobotStateMapEntityClasses(t *testing.T) {
+	data := `{"entities":[
+		{"type":"robot_position","points":[1,2]},
+		{"type":"path","points":[1,2,3,4]},
+		{"type":"no_go_area","points":[0,0,1,0,1,1,0,1]},
+		{"type":"virtual_wall","points":[0,0,5,5]},
+		{"__class":"PointMapEntity","type":"obstacle","points":[3,3]},
+		{"type":"something_new"}
+	]}`
+
+	robotMap, err := ParseRobotStateMap([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		PointMapEntityClass,
+		PathMapEntityClass,
+		PolygonMapEntityClass,
+		LineMapEntityClass,
+		PointMapEntityClass,
+		"",
+	}
+
+	for i, entity := range robotMap.Entities {
+		if entity.Class != expected[i] {
+			t.Errorf("%s: expected class %q, got %q", entity.Type, expected[i], entity.Class)
+		}
+	}
+}
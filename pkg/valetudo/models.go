@@ -1,38 +1,63 @@
 package valetudo
 
+import "encoding/json"
+
 type RobotStateRemainingAttribute struct {
 	Value *int    `json:"value"`
 	Unit  *string `json:"unit"`
 }
 
-type RobotStateAttribute struct {
-	Class       string                        `json:"__class"`
-	Type        *string                       `json:"type"`
-	SubType     *string                       `json:"subType"`
-	Value       *string                       `json:"value"`
-	CustomValue *string                       `json:"customValue"`
-	Attached    *bool                         `json:"attached,omitempty"`
-	Level       *int                          `json:"level,omitempty"`
-	Flag        *string                       `json:"flag,omitempty"`
-	Remaining   *RobotStateRemainingAttribute `json:"remaining,omitempty"`
+type ValetudoRobotErrorSeverity struct {
+	// transient, permanent or unknown
+	Kind string `json:"kind"`
+	// none, info, warning, error, catastrophic or unknown
+	Level string `json:"level"`
+}
+
+// ValetudoRobotError is reported by StatusStateAttribute when the robot is in error state
+type ValetudoRobotError struct {
+	Severity ValetudoRobotErrorSeverity `json:"severity"`
+	// core, power, sensors, motors, navigation, attachments, docking or unknown
+	Subsystem string `json:"subsystem"`
+	Message   string `json:"message"`
 }
 
 type RobotStateMap struct {
+	Class     string                `json:"__class"`
+	Metadata  RobotStateMapMetadata `json:"metaData"`
 	Size      RobotStateMapSize     `json:"size"`
 	PixelSize int                   `json:"pixelSize"`
 	Layers    []RobotStateMapLayer  `json:"layers"`
 	Entities  []RobotStateMapEntity `json:"entities"`
 }
 
+type RobotStateMapMetadata struct {
+	// format of the map, see RobotStateMapVersion
+	Version int `json:"version"`
+	// changes whenever the map changes
+	Nonce string `json:"nonce,omitempty"`
+	// sum of pixel counts of all layers
+	TotalLayerArea int `json:"totalLayerArea,omitempty"`
+}
+
+// RobotStateMapEntity is point, path, polygon or line, see the *MapEntityClass and MapEntity* constants
 type RobotStateMapEntity struct {
 	Class    string                      `json:"__class"`
 	Metadata RobotStateMapEntityMetadata `json:"metaData"`
 	Type     string                      `json:"type"`
-	Points   *[]int                      `json:"points,omitempty"`
+	// flat list of x and y coordinates in cm
+	Points *[]int `json:"points,omitempty"`
 }
 
 type RobotStateMapEntityMetadata struct {
+	// rotation of robot_position in degrees
 	Angle *float64 `json:"angle,omitempty"`
+	// obstacle identifier, used to fetch its image
+	Id *string `json:"id,omitempty"`
+	// what the obstacle is, for example "Cable"
+	Label *string `json:"label,omitempty"`
+	// how sure the robot is about the obstacle label, 0 to 1
+	Confidence *float64 `json:"confidence,omitempty"`
 }
 
 type RobotStateMapSize struct {
@@ -40,17 +65,22 @@ type RobotStateMapSize struct {
 	Y int `json:"y"`
 }
 
+// RobotStateMapLayer is floor, wall or segment, see MapLayer* constants
 type RobotStateMapLayer struct {
-	Type             string                       `json:"type"`
-	Metadata         RobotStateMapLayerMetadata   `json:"metaData"`
-	Dimensions       RobotStateMapLayerDimensions `json:"dimensions"`
-	Pixels           []int                        `json:"pixels"`
-	CompressedPixels []int                        `json:"compressedPixels"`
+	Class      string                       `json:"__class"`
+	Type       string                       `json:"type"`
+	Metadata   RobotStateMapLayerMetadata   `json:"metaData"`
+	Dimensions RobotStateMapLayerDimensions `json:"dimensions"`
+	// flat list of x and y coordinates, only used by old maps
+	Pixels []int `json:"pixels"`
+	// runs of pixels as x, y and count triples
+	CompressedPixels []int `json:"compressedPixels"`
 }
 
 type RobotStateMapLayerDimensions struct {
-	X RobotStateMapDimensionData `json:"x"`
-	Y RobotStateMapDimensionData `json:"y"`
+	X          RobotStateMapDimensionData `json:"x"`
+	Y          RobotStateMapDimensionData `json:"y"`
+	PixelCount int                        `json:"pixelCount"`
 }
 
 type RobotStateMapDimensionData struct {
@@ -65,11 +95,13 @@ type RobotStateMapLayerMetadata struct {
 	SegmentId *string `json:"segmentId"`
 	Active    *bool   `json:"active"`
 	Name      *string `json:"name"`
+	// floor material of segment, see MapLayerMaterial* constants
+	Material *string `json:"material,omitempty"`
 }
 
 type RobotState struct {
-	Attributes []RobotStateAttribute `json:"attributes"`
-	Map        RobotStateMap         `json:"map"`
+	Attributes RobotStateAttributes `json:"attributes"`
+	Map        RobotStateMap        `json:"map"`
 }
 
 type MapSegmentationCapabilityPutRequest struct {
@@ -79,6 +111,11 @@ type MapSegmentationCapabilityPutRequest struct {
 	CustomOrder *bool    `json:"custom_order,omitempty"`
 }
 
+type MapSegmentationCapabilityProperties struct {
+	IterationCount     CapabilityRangeProperty `json:"iterationCount"`
+	CustomOrderSupport bool                    `json:"customOrderSupport"`
+}
+
 type BasicControlCapabilityRequest struct {
 	Action string `json:"action"`
 }
@@ -86,3 +123,180 @@ type BasicControlCapabilityRequest struct {
 type PutRobotCapabilityPresetRequest struct {
 	Name string `json:"name"`
 }
+
+type MapPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type ZoneCleaningZonePoints struct {
+	PA MapPoint `json:"pA"`
+	PB MapPoint `json:"pB"`
+	PC MapPoint `json:"pC"`
+	PD MapPoint `json:"pD"`
+}
+
+type ZoneCleaningZone struct {
+	Points ZoneCleaningZonePoints `json:"points"`
+}
+
+type ZoneCleaningCapabilityPutRequest struct {
+	Action     string             `json:"action"`
+	Zones      []ZoneCleaningZone `json:"zones"`
+	Iterations *int               `json:"iterations,omitempty"`
+}
+
+type CapabilityRangeProperty struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type ZoneCleaningCapabilityProperties struct {
+	ZoneCount  CapabilityRangeProperty `json:"zoneCount"`
+	Iterations CapabilityRangeProperty `json:"iterations"`
+}
+
+type GoToLocationCapabilityPutRequest struct {
+	Action      string   `json:"action"`
+	Coordinates MapPoint `json:"coordinates"`
+}
+
+type ConsumableResetRequest struct {
+	Action string `json:"action"`
+}
+
+type MapSegmentRenameCapabilityPutRequest struct {
+	Action    string `json:"action"`
+	SegmentId string `json:"segment_id"`
+	Name      string `json:"name"`
+}
+
+type MapSegmentEditJoinRequest struct {
+	Action     string `json:"action"`
+	SegmentAId string `json:"segment_a_id"`
+	SegmentBId string `json:"segment_b_id"`
+}
+
+type MapSegmentEditSplitRequest struct {
+	Action    string   `json:"action"`
+	SegmentId string   `json:"segment_id"`
+	PA        MapPoint `json:"pA"`
+	PB        MapPoint `json:"pB"`
+}
+
+type VirtualWallPoints struct {
+	PA MapPoint `json:"pA"`
+	PB MapPoint `json:"pB"`
+}
+
+type VirtualWall struct {
+	Points VirtualWallPoints `json:"points"`
+}
+
+type RestrictedZone struct {
+	Points ZoneCleaningZonePoints `json:"points"`
+	// "regular" for no-go areas, "mop" for no-mop areas
+	Type string `json:"type"`
+}
+
+type CombinedVirtualRestrictions struct {
+	VirtualWalls    []VirtualWall    `json:"virtualWalls"`
+	RestrictedZones []RestrictedZone `json:"restrictedZones"`
+}
+
+type CombinedVirtualRestrictionsCapabilityProperties struct {
+	SupportedRestrictedZoneTypes []string `json:"supportedRestrictedZoneTypes"`
+}
+
+type ManualControlCapabilityPutRequest struct {
+	Action string `json:"action"`
+	// forward, backward, rotate_clockwise or rotate_counterclockwise
+	MovementCommand string `json:"movementCommand,omitempty"`
+}
+
+type HighResolutionManualControlVector struct {
+	// -1 to 1
+	Velocity float64 `json:"velocity"`
+	// -180 to 180 degrees
+	Angle float64 `json:"angle"`
+}
+
+type HighResolutionManualControlCapabilityPutRequest struct {
+	Action string                             `json:"action"`
+	Vector *HighResolutionManualControlVector `json:"vector,omitempty"`
+}
+
+type ValetudoTimerActionParams struct {
+	SegmentIds  []string `json:"segment_ids,omitempty"`
+	Iterations  *int     `json:"iterations,omitempty"`
+	CustomOrder *bool    `json:"custom_order,omitempty"`
+}
+
+type ValetudoTimerAction struct {
+	// full_cleanup or segment_cleanup
+	Type   string                    `json:"type"`
+	Params ValetudoTimerActionParams `json:"params"`
+}
+
+// ValetudoTimer is a timer executed by the robot itself, time is in UTC
+type ValetudoTimer struct {
+	Id      string `json:"id,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Label   string `json:"label,omitempty"`
+	// days of week, 0 is Sunday
+	Dow        []int               `json:"dow"`
+	Hour       int                 `json:"hour"`
+	Minute     int                 `json:"minute"`
+	Action     ValetudoTimerAction `json:"action"`
+	PreActions []json.RawMessage   `json:"pre_actions,omitempty"`
+}
+
+type ValetudoTimersProperties struct {
+	SupportedActions    []string `json:"supportedActions"`
+	SupportedPreActions []string `json:"supportedPreActions"`
+}
+
+// ValetudoDataPoint is a statistics value, time is in seconds and area in cm²
+type ValetudoDataPoint struct {
+	Timestamp string  `json:"timestamp"`
+	Type      string  `json:"type"`
+	Value     float64 `json:"value"`
+}
+
+const (
+	DustBinFullEventClass           = "DustBinFullValetudoEvent"
+	ConsumableDepletedEventClass    = "ConsumableDepletedValetudoEvent"
+	MopAttachmentReminderEventClass = "MopAttachmentReminderValetudoEvent"
+	PendingMapChangeEventClass      = "PendingMapChangeValetudoEvent"
+	ErrorStateEventClass            = "ErrorStateValetudoEvent"
+	MissingResourceEventClass       = "MissingResourceValetudoEvent"
+)
+
+const (
+	// dismisses the event
+	EventInteractionOk = "ok"
+	// accepts or rejects pending map change
+	EventInteractionYes = "yes"
+	EventInteractionNo  = "no"
+	// resets depleted consumable
+	EventInteractionReset = "reset"
+)
+
+// ValetudoEvent is raised by Valetudo and stays unprocessed until somebody interacts with it
+type ValetudoEvent struct {
+	Class     string `json:"__class"`
+	Id        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Processed bool   `json:"processed"`
+
+	// consumable of ConsumableDepletedValetudoEvent
+	Type    string `json:"type,omitempty"`
+	SubType string `json:"subType,omitempty"`
+
+	// description of ErrorStateValetudoEvent and MissingResourceValetudoEvent
+	Message string `json:"message,omitempty"`
+}
+
+type ValetudoEventInteractionRequest struct {
+	Interaction string `json:"interaction"`
+}
@@ -0,0 +1,164 @@
+package valetudo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/r3labs/sse/v2"
+)
+
+// ClientOptions configure connection to Valetudo, zero value connects the same way as Init
+type ClientOptions struct {
+	// HTTP basic auth, credentials in the URL take precedence over these
+	Username string
+	Password string
+
+	// PEM file with certificates trusted in addition to the system ones, for self-signed reverse proxies
+	CAFile string
+	// PEM files with client certificate and its key, for proxies requiring mutual TLS
+	CertFile string
+	KeyFile  string
+	// don't verify server certificate at all
+	InsecureSkipVerify bool
+
+	// sent with every request, for example authentication of a reverse proxy
+	Headers map[string]string
+}
+
+// InitWithOptions creates client with authentication and TLS settings, fails when certificate files can't be loaded
+// or when only one of the client certificate and its key is set
+func InitWithOptions(baseUrl string, options ClientOptions) (ValetudoClient, error) {
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return ValetudoClient{}, fmt.Errorf("client certificate and its key have to be set together")
+	}
+
+	parsed, err := url.Parse(baseUrl)
+	if err != nil {
+		return ValetudoClient{}, fmt.Errorf("invalid valetudo url: %w", err)
+	}
+
+	// credentials in the url are specific to the robot, so they win over the shared ones
+	if parsed.User != nil {
+		options.Username = parsed.User.Username()
+		options.Password, _ = parsed.User.Password()
+
+		parsed.User = nil
+	}
+
+	httpClient, err := buildHttpClient(options)
+	if err != nil {
+		return ValetudoClient{}, err
+	}
+
+	return ValetudoClient{
+		Url:        parsed.String(),
+		options:    options,
+		httpClient: httpClient,
+	}, nil
+}
+
+func buildHttpClient(options ClientOptions) (*http.Client, error) {
+	if options.CAFile == "" && options.CertFile == "" && !options.InsecureSkipVerify {
+		return http.DefaultClient, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", options.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	// no timeout, SSE connections stay open
+	return &http.Client{Transport: transport}, nil
+}
+
+func (client *ValetudoClient) getHttpClient() *http.Client {
+	if client.httpClient == nil {
+		return http.DefaultClient
+	}
+
+	return client.httpClient
+}
+
+// getHeaders returns custom headers together with basic auth
+func (client *ValetudoClient) getHeaders() map[string]string {
+	headers := map[string]string{}
+
+	for name, value := range client.options.Headers {
+		headers[name] = value
+	}
+
+	if client.options.Username != "" || client.options.Password != "" {
+		credentials := client.options.Username + ":" + client.options.Password
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	return headers
+}
+
+func (client *ValetudoClient) newRequest(method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, client.Url+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range client.getHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+func (client *ValetudoClient) get(path string) (*http.Response, error) {
+	req, err := client.newRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.getHttpClient().Do(req)
+}
+
+func (client *ValetudoClient) newSseClient(path string) *sse.Client {
+	sseClient := sse.NewClient(client.Url + path)
+	sseClient.Connection = client.getHttpClient()
+
+	for name, value := range client.getHeaders() {
+		sseClient.Headers[name] = value
+	}
+
+	return sseClient
+}
@@ -0,0 +1,22 @@
+package valetudo
+
+import "testing"
+
+func TestInitWithOptionsCertificatePair(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ClientOptions
+	}{
+		{name: "certificate only", options: ClientOptions{CertFile: "client.pem"}},
+		{name: "key only", options: ClientOptions{KeyFile: "client.key"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := InitWithOptions("http://192.168.0.1", test.options)
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
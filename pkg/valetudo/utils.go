@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 )
 
 func ParseRobotState(body []byte) (*RobotState, error) {
@@ -17,11 +16,13 @@ func ParseRobotState(body []byte) (*RobotState, error) {
 		return nil, err
 	}
 
+	MigrateRobotStateMap(&state.Map)
+
 	return &state, nil
 }
 
-func ParseRobotStateAttributes(body []byte) (*[]RobotStateAttribute, error) {
-	result := []RobotStateAttribute{}
+func ParseRobotStateAttributes(body []byte) (*RobotStateAttributes, error) {
+	result := RobotStateAttributes{}
 
 	err := json.Unmarshal(body, &result)
 	if err != nil {
@@ -31,21 +32,30 @@ func ParseRobotStateAttributes(body []byte) (*[]RobotStateAttribute, error) {
 	return &result, nil
 }
 
+// PushRequest sends data as JSON body, nil data sends request without body
 func (client *ValetudoClient) PushRequest(method string, url string, data interface{}) error {
-	requestBytes, err := json.Marshal(data)
-	if err != nil {
-		return err
+	var body io.Reader
+
+	if data != nil {
+		requestBytes, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(requestBytes)
 	}
 
-	req, err := http.NewRequest(method, client.Url+url, bytes.NewReader(requestBytes))
+	req, err := client.newRequest(method, url, body)
 
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := client.getHttpClient().Do(req)
 	if err != nil {
 		return err
 	}
@@ -66,7 +76,7 @@ func (client *ValetudoClient) PushRequest(method string, url string, data interf
 }
 
 func (client *ValetudoClient) GetRequest(url string, unmarshalInto any) error {
-	res, err := http.Get(client.Url + url)
+	res, err := client.get(url)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -115,3 +125,81 @@ func (client *ValetudoClient) setRobotCapabilityPreset(capability string, preset
 
 	return nil
 }
+
+// NewRectangleZone creates zone from two opposite corners of rectangle, coordinates are in cm
+func NewRectangleZone(x1 int, y1 int, x2 int, y2 int) ZoneCleaningZone {
+	left, right := min(x1, x2), max(x1, x2)
+	top, bottom := min(y1, y2), max(y1, y2)
+
+	return ZoneCleaningZone{
+		Points: ZoneCleaningZonePoints{
+			PA: MapPoint{X: left, Y: top},
+			PB: MapPoint{X: right, Y: top},
+			PC: MapPoint{X: right, Y: bottom},
+			PD: MapPoint{X: left, Y: bottom},
+		},
+	}
+}
+
+// FindEntity returns first map entity of specified type or nil
+func (robotMap *RobotStateMap) FindEntity(entityType string) *RobotStateMapEntity {
+	for i := range robotMap.Entities {
+		if robotMap.Entities[i].Type == entityType {
+			return &robotMap.Entities[i]
+		}
+	}
+
+	return nil
+}
+
+// GetVirtualRestrictions collects virtual walls and no-go/no-mop areas from map entities
+func (robotMap *RobotStateMap) GetVirtualRestrictions() CombinedVirtualRestrictions {
+	result := CombinedVirtualRestrictions{
+		VirtualWalls:    []VirtualWall{},
+		RestrictedZones: []RestrictedZone{},
+	}
+
+	for _, entity := range robotMap.Entities {
+		if entity.Points == nil {
+			continue
+		}
+
+		points := *entity.Points
+
+		switch entity.Type {
+		case MapEntityVirtualWall:
+			if len(points) < 4 {
+				continue
+			}
+
+			result.VirtualWalls = append(result.VirtualWalls, VirtualWall{
+				Points: VirtualWallPoints{
+					PA: MapPoint{X: points[0], Y: points[1]},
+					PB: MapPoint{X: points[2], Y: points[3]},
+				},
+			})
+
+		case MapEntityNoGoArea, MapEntityNoMopArea:
+			if len(points) < 8 {
+				continue
+			}
+
+			zoneType := "regular"
+			if entity.Type == MapEntityNoMopArea {
+				zoneType = "mop"
+			}
+
+			result.RestrictedZones = append(result.RestrictedZones, RestrictedZone{
+				Points: ZoneCleaningZonePoints{
+					PA: MapPoint{X: points[0], Y: points[1]},
+					PB: MapPoint{X: points[2], Y: points[3]},
+					PC: MapPoint{X: points[4], Y: points[5]},
+					PD: MapPoint{X: points[6], Y: points[7]},
+				},
+				Type: zoneType,
+			})
+		}
+	}
+
+	return result
+}
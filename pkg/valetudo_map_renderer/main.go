@@ -15,6 +15,27 @@ import (
 var vacuumImage *image.Image
 var chargerImage *image.Image
 
+// MapMarker is a labeled point drawn on top of the map, coordinates are in cm
+type MapMarker struct {
+	X     int
+	Y     int
+	Label string
+}
+
+// MapCrop limits rendered area to a square around the center, values are in cm
+type MapCrop struct {
+	X      int
+	Y      int
+	Radius int
+}
+
+type RenderOptions struct {
+	Markers []MapMarker
+	Crop    *MapCrop
+	// defaults to 2 when not set
+	Scale float64
+}
+
 func getLayerOrder(layer valetudo.RobotStateMapLayer) int {
 	if layer.Type == "wall" {
 		return 3
@@ -30,13 +51,19 @@ func getLayerOrder(layer valetudo.RobotStateMapLayer) int {
 }
 
 func getEntityOrder(entity valetudo.RobotStateMapEntity) int {
+	if entity.Type == "no_go_area" || entity.Type == "no_mop_area" || entity.Type == "active_zone" {
+		return -2
+	}
+	if entity.Type == "virtual_wall" {
+		return -1
+	}
 	if entity.Type == "path" {
 		return 0
 	}
 	if entity.Type == "predicted_path" {
 		return 1
 	}
-	if entity.Type == "charger_location" {
+	if entity.Type == "charger_location" || entity.Type == "obstacle" || entity.Type == "go_to_target" {
 		return 2
 	}
 	if entity.Type == "robot_position" {
@@ -47,6 +74,10 @@ func getEntityOrder(entity valetudo.RobotStateMapEntity) int {
 }
 
 func RenderMap(mapData *valetudo.RobotStateMap) []byte {
+	return RenderMapWithOptions(mapData, RenderOptions{})
+}
+
+func RenderMapWithOptions(mapData *valetudo.RobotStateMap, options RenderOptions) []byte {
 	if vacuumImage == nil {
 		img, _, err := image.Decode(bytes.NewReader(assets.VacuumImage))
 		if err != nil {
@@ -64,6 +95,9 @@ func RenderMap(mapData *valetudo.RobotStateMap) []byte {
 	}
 
 	scale := 2.0
+	if options.Scale > 0 {
+		scale = options.Scale
+	}
 
 	w := int(math.Round(float64(mapData.Size.X) / float64(mapData.PixelSize)))
 	h := int(math.Round(float64(mapData.Size.Y) / float64(mapData.PixelSize)))
@@ -112,6 +146,17 @@ func RenderMap(mapData *valetudo.RobotStateMap) []byte {
 	maxX += int(float64(h) * 0.01)
 	maxY += int(float64(h) * 0.01)
 
+	if options.Crop != nil {
+		centerX := options.Crop.X / mapData.PixelSize
+		centerY := options.Crop.Y / mapData.PixelSize
+		radius := options.Crop.Radius / mapData.PixelSize
+
+		minX = centerX - radius
+		minY = centerY - radius
+		maxX = centerX + radius
+		maxY = centerY + radius
+	}
+
 	resizedW := int(math.Round(float64(maxX-minX) * scale))
 	resizedH := int(math.Round(float64(maxY-minY) * scale))
 
@@ -152,9 +197,66 @@ func RenderMap(mapData *valetudo.RobotStateMap) []byte {
 	})
 
 	for _, entity := range mapData.Entities {
+		if entity.Points == nil || len(*entity.Points) < 2 {
+			continue
+		}
+
 		x := ((float64((*entity.Points)[0]) / float64(mapData.PixelSize)) - float64(minX)) * scale
 		y := ((float64((*entity.Points)[1]) / float64(mapData.PixelSize)) - float64(minY)) * scale
 
+		if entity.Type == "no_go_area" || entity.Type == "no_mop_area" || entity.Type == "active_zone" {
+			fillColor := color.RGBA{255, 0, 0, 60}
+			strokeColor := color.RGBA{255, 0, 0, 200}
+			if entity.Type == "no_mop_area" {
+				fillColor = color.RGBA{0, 100, 255, 60}
+				strokeColor = color.RGBA{0, 100, 255, 200}
+			}
+			if entity.Type == "active_zone" {
+				fillColor = color.RGBA{0, 200, 80, 60}
+				strokeColor = color.RGBA{0, 200, 80, 200}
+			}
+
+			ctx.MoveTo(x, y)
+			for i := 2; i+1 < len(*entity.Points); i += 2 {
+				ctx.LineTo(
+					((float64((*entity.Points)[i])/float64(mapData.PixelSize))-float64(minX))*scale,
+					((float64((*entity.Points)[i+1])/float64(mapData.PixelSize))-float64(minY))*scale,
+				)
+			}
+			ctx.ClosePath()
+
+			ctx.SetColor(fillColor)
+			ctx.FillPreserve()
+			ctx.SetColor(strokeColor)
+			ctx.SetLineWidth(1)
+			ctx.Stroke()
+		}
+
+		if entity.Type == "virtual_wall" && len(*entity.Points) >= 4 {
+			ctx.SetColor(color.RGBA{255, 0, 0, 255})
+			ctx.SetLineWidth(3)
+			ctx.DrawLine(
+				x,
+				y,
+				((float64((*entity.Points)[2])/float64(mapData.PixelSize))-float64(minX))*scale,
+				((float64((*entity.Points)[3])/float64(mapData.PixelSize))-float64(minY))*scale,
+			)
+			ctx.Stroke()
+		}
+
+		if entity.Type == "obstacle" {
+			ctx.DrawCircle(x, y, 4)
+			ctx.SetColor(color.RGBA{255, 140, 0, 255})
+			ctx.Fill()
+		}
+
+		if entity.Type == "go_to_target" {
+			ctx.DrawCircle(x, y, 6)
+			ctx.SetColor(color.RGBA{0, 200, 80, 255})
+			ctx.SetLineWidth(2)
+			ctx.Stroke()
+		}
+
 		if entity.Type == "charger_location" {
 			ctx.DrawImageAnchored(*chargerImage, int(x), int(y), 0.5, 0.5)
 		}
@@ -192,6 +294,13 @@ func RenderMap(mapData *valetudo.RobotStateMap) []byte {
 		}
 	}
 
+	for _, marker := range options.Markers {
+		x := ((float64(marker.X) / float64(mapData.PixelSize)) - float64(minX)) * scale
+		y := ((float64(marker.Y) / float64(mapData.PixelSize)) - float64(minY)) * scale
+
+		renderMarker(ctx, x, y, marker.Label)
+	}
+
 	ctx.Scale(3, 3)
 	ctx.Stroke()
 
@@ -223,3 +332,21 @@ func renderLayer(ctx *gg.Context, layer valetudo.RobotStateMapLayer, xOffset int
 		}
 	}
 }
+
+func renderMarker(ctx *gg.Context, x float64, y float64, label string) {
+	ctx.SetColor(color.RGBA{220, 50, 50, 255})
+	ctx.DrawCircle(x, y, 4)
+	ctx.Fill()
+
+	if label == "" {
+		return
+	}
+
+	textWidth, textHeight := ctx.MeasureString(label)
+	ctx.SetColor(color.RGBA{255, 255, 255, 200})
+	ctx.DrawRectangle(x+6, y-textHeight/2-2, textWidth+4, textHeight+4)
+	ctx.Fill()
+
+	ctx.SetColor(color.RGBA{0, 0, 0, 255})
+	ctx.DrawStringAnchored(label, x+8, y, 0, 0.5)
+}